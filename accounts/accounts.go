@@ -0,0 +1,208 @@
+/*
+ * Github Authorized Keys - Use GitHub teams to manage system user accounts and authorized_keys
+ *
+ * Copyright 2016 Cloud Posse, LLC <hello@cloudposse.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package accounts
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/terjekv/github-authorized-keys/model/linux"
+)
+
+// Policies applied when GitHub login of managed account changes
+const (
+	// RenamePolicyRename - rename linux account and move its home directory
+	RenamePolicyRename = linux.RenamePolicyRename
+	// RenamePolicyAlias - keep linux account name, keys are looked up by the new GitHub login
+	RenamePolicyAlias = linux.RenamePolicyAlias
+	// RenamePolicyNone - do not track renames, new linux account is created for the new login
+	RenamePolicyNone = linux.RenamePolicyNone
+)
+
+// Account - linux account managed for GitHub user
+type Account struct {
+	GithubID int64  `json:"github_id"`
+	Login    string `json:"login"`
+	Linux    string `json:"linux"`
+	// Alias - account kept its name after the login changed, so it is not renamed when mapping rules change
+	Alias     bool      `json:"alias,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// State - linux accounts keyed by GitHub user id, persisted as JSON file
+type State struct {
+	path     string
+	accounts map[int64]Account
+}
+
+type stateFile struct {
+	Accounts map[string]Account `json:"accounts"`
+}
+
+// Load - read state from file {path}, missing file is empty state
+func Load(path string) (*State, error) {
+	state := &State{path: path, accounts: map[int64]Account{}}
+
+	content, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	file := stateFile{}
+	if err := json.Unmarshal(content, &file); err != nil {
+		return nil, err
+	}
+	for _, account := range file.Accounts {
+		state.accounts[account.GithubID] = account
+	}
+	return state, nil
+}
+
+// Get - return account of GitHub user {githubID}
+func (s *State) Get(githubID int64) (Account, bool) {
+	account, ok := s.accounts[githubID]
+	return account, ok
+}
+
+// ByLinux - return account with linux name {name}
+func (s *State) ByLinux(name string) (Account, bool) {
+	for _, account := range s.accounts {
+		if account.Linux == name {
+			return account, true
+		}
+	}
+	return Account{}, false
+}
+
+// Set - store {account}, update time is set when login, linux name or alias flag changes
+func (s *State) Set(account Account) {
+	if current, ok := s.accounts[account.GithubID]; ok && current.Login == account.Login && current.Linux == account.Linux &&
+		current.Alias == account.Alias {
+		return
+	}
+	account.UpdatedAt = time.Now().UTC()
+	s.accounts[account.GithubID] = account
+}
+
+// Accounts - return all accounts ordered by GitHub user id
+func (s *State) Accounts() []Account {
+	accounts := make([]Account, 0, len(s.accounts))
+	for _, account := range s.accounts {
+		accounts = append(accounts, account)
+	}
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].GithubID < accounts[j].GithubID })
+	return accounts
+}
+
+// Save - atomically write state to its file
+func (s *State) Save() error {
+	file := stateFile{Accounts: map[string]Account{}}
+	for id, account := range s.accounts {
+		file.Accounts[strconv.FormatInt(id, 10)] = account
+	}
+
+	content, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return err
+	}
+
+	temp, err := ioutil.TempFile(filepath.Dir(s.path), "."+filepath.Base(s.path)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	if _, err := temp.Write(append(content, '\n')); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Chmod(0644); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), s.path)
+}
+
+// cachedState - state shared by lookups, read again only when its file changes
+var cachedState struct {
+	sync.Mutex
+	path    string
+	modTime time.Time
+	state   *State
+}
+
+// GithubLogin - return GitHub login of linux user {name} mapped by {mapping}. Overrides are checked first, then logins recorded
+// in state file {path} (renamed, aliased and truncated accounts), then the mapping rules are reversed.
+func GithubLogin(mapping linux.Mapping, path, name string) (string, bool) {
+	name = strings.ToLower(name)
+	if login, ok := mapping.OverriddenLogin(name); ok {
+		return login, true
+	}
+
+	if login, ok := recordedLogin(path, name); ok {
+		return login, true
+	}
+
+	return mapping.Reverse(name)
+}
+
+// recordedLogin - return GitHub login of linux account {name} recorded in state file {path}
+func recordedLogin(path, name string) (string, bool) {
+	if path == "" {
+		return "", false
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", false
+	}
+
+	cachedState.Lock()
+	defer cachedState.Unlock()
+
+	if cachedState.state == nil || cachedState.path != path || !cachedState.modTime.Equal(info.ModTime()) {
+		state, err := Load(path)
+		if err != nil {
+			return "", false
+		}
+		cachedState.path, cachedState.modTime, cachedState.state = path, info.ModTime(), state
+	}
+
+	if account, ok := cachedState.state.ByLinux(name); ok {
+		return account.Login, true
+	}
+	return "", false
+}
@@ -0,0 +1,182 @@
+/*
+ * Github Authorized Keys - Use GitHub teams to manage system user accounts and authorized_keys
+ *
+ * Copyright 2016 Cloud Posse, LLC <hello@cloudposse.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package accounts
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/terjekv/github-authorized-keys/model/linux"
+)
+
+var _ = Describe("State", func() {
+	var (
+		dir  string
+		path string
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "gak-accounts")
+		Expect(err).To(BeNil())
+		path = filepath.Join(dir, "lib", "users.json")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	Describe("Load()", func() {
+		Context("call with missing file", func() {
+			It("should return empty state", func() {
+				state, err := Load(path)
+				Expect(err).To(BeNil())
+				Expect(state.Accounts()).To(BeEmpty())
+			})
+		})
+
+		Context("call with malformed file", func() {
+			It("should return error", func() {
+				Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(BeNil())
+				Expect(ioutil.WriteFile(path, []byte("{"), 0644)).To(BeNil())
+				_, err := Load(path)
+				Expect(err).NotTo(BeNil())
+			})
+		})
+	})
+
+	Describe("Save()", func() {
+		It("should write accounts loaded again", func() {
+			state, err := Load(path)
+			Expect(err).To(BeNil())
+			state.Set(Account{GithubID: 2, Login: "bob", Linux: "bob"})
+			state.Set(Account{GithubID: 1, Login: "alice", Linux: "gh_alice"})
+			Expect(state.Save()).To(BeNil())
+
+			loaded, err := Load(path)
+			Expect(err).To(BeNil())
+			accounts := loaded.Accounts()
+			Expect(accounts).To(HaveLen(2))
+			Expect(accounts[0].Login).To(Equal("alice"))
+			Expect(accounts[0].Linux).To(Equal("gh_alice"))
+			Expect(accounts[1].Login).To(Equal("bob"))
+
+			account, ok := loaded.ByLinux("gh_alice")
+			Expect(ok).To(BeTrue())
+			Expect(account.GithubID).To(Equal(int64(1)))
+		})
+	})
+
+	Describe("Set()", func() {
+		It("should update time only when login or linux name changes", func() {
+			state, _ := Load(path)
+			state.Set(Account{GithubID: 1, Login: "alice", Linux: "alice"})
+			first, _ := state.Get(1)
+			Expect(first.UpdatedAt.IsZero()).To(BeFalse())
+
+			state.Set(Account{GithubID: 1, Login: "alice", Linux: "alice"})
+			same, _ := state.Get(1)
+			Expect(same.UpdatedAt).To(Equal(first.UpdatedAt))
+
+			state.Set(Account{GithubID: 1, Login: "alice2", Linux: "alice"})
+			renamed, _ := state.Get(1)
+			Expect(renamed.Login).To(Equal("alice2"))
+			Expect(renamed.UpdatedAt.Before(first.UpdatedAt)).To(BeFalse())
+		})
+	})
+
+	Describe("recordedLogin()", func() {
+		save := func(login string, modTime time.Time) {
+			state, _ := Load(path)
+			state.Set(Account{GithubID: 1, Login: login, Linux: "alice"})
+			Expect(state.Save()).To(BeNil())
+			Expect(os.Chtimes(path, modTime, modTime)).To(BeNil())
+		}
+
+		It("should return login of recorded account", func() {
+			save("alice", time.Now())
+			login, ok := recordedLogin(path, "alice")
+			Expect(ok).To(BeTrue())
+			Expect(login).To(Equal("alice"))
+
+			_, ok = recordedLogin(path, "bob")
+			Expect(ok).To(BeFalse())
+		})
+
+		It("should read file again only when it changes", func() {
+			modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+			save("alice", modTime)
+			login, _ := recordedLogin(path, "alice")
+			Expect(login).To(Equal("alice"))
+
+			save("alice2", modTime)
+			login, _ = recordedLogin(path, "alice")
+			Expect(login).To(Equal("alice"))
+
+			save("alice2", modTime.Add(time.Minute))
+			login, _ = recordedLogin(path, "alice")
+			Expect(login).To(Equal("alice2"))
+		})
+
+		It("should not find accounts without state file", func() {
+			_, ok := recordedLogin("", "alice")
+			Expect(ok).To(BeFalse())
+			_, ok = recordedLogin(path, "alice")
+			Expect(ok).To(BeFalse())
+		})
+	})
+})
+
+var _ = Describe("GithubLogin()", func() {
+	mapping := linux.NewMapping(map[string]string{"boss": "admin"}, []linux.Substitution{{From: "-", To: "_"}}, "gh_", "", 16)
+
+	It("should check overrides, then reverse mapping rules", func() {
+		login, ok := GithubLogin(mapping, "", "admin")
+		Expect(ok).To(BeTrue())
+		Expect(login).To(Equal("boss"))
+
+		login, ok = GithubLogin(mapping, "", "gh_cloud_posse")
+		Expect(ok).To(BeTrue())
+		Expect(login).To(Equal("cloud-posse"))
+
+		_, ok = GithubLogin(mapping, "", "root")
+		Expect(ok).To(BeFalse())
+	})
+
+	It("should find truncated names in state file", func() {
+		dir, err := ioutil.TempDir("", "gak-accounts")
+		Expect(err).To(BeNil())
+		defer os.RemoveAll(dir)
+
+		path := filepath.Join(dir, "users.json")
+		state, err := Load(path)
+		Expect(err).To(BeNil())
+		name := mapping.LinuxName("a-very-long-github-login")
+		state.Set(Account{GithubID: 42, Login: "a-very-long-github-login", Linux: name})
+		Expect(state.Save()).To(BeNil())
+
+		login, ok := GithubLogin(mapping, path, name)
+		Expect(ok).To(BeTrue())
+		Expect(login).To(Equal("a-very-long-github-login"))
+	})
+})
@@ -21,11 +21,12 @@ package api
 import (
 	"context"
 	"errors"
+	"strconv"
 	"strings"
 
 	"github.com/google/go-github/v43/github"
 	log "github.com/sirupsen/logrus"
-	"github.com/spf13/viper"
+	"github.com/terjekv/github-authorized-keys/metrics"
 	"golang.org/x/oauth2"
 )
 
@@ -38,11 +39,13 @@ var (
 
 	// ErrorGitHubNotFound - returned when github.com resource not found
 	ErrorGitHubNotFound = errors.New("Not found")
+
+	// ErrorGitHubTeamNotFound - returned when organization has no team with given name or id
+	ErrorGitHubTeamNotFound = errors.New("No such team name or id could be found")
 )
 
-func init() {
-	viper.SetDefault("github_api_max_page_size", 100)
-}
+// GithubAPIMaxPageSizeDefault - items requested per page of GitHub API lists, 100 is the maximum GitHub allows
+const GithubAPIMaxPageSizeDefault = 100
 
 // Naive oauth setup
 func newAccessToken(token string) oauth2.TokenSource {
@@ -56,6 +59,7 @@ type GithubClient struct {
 	client         *github.Client
 	owner          string
 	organizationId *int64
+	pageSize       int
 }
 
 // GetTeam - return team structure based on name or id
@@ -71,11 +75,12 @@ func (c *GithubClient) GetTeam(name string, id int) (team *github.Team, err erro
 	err = nil
 
 	var opt = &github.ListOptions{
-		PerPage: viper.GetInt("github_api_max_page_size"),
+		PerPage: c.pageSize,
 	}
 
 	for {
 		teams, response, _ := c.client.Teams.ListTeams(context.Background(), c.owner, opt)
+		observe("teams", response)
 
 		if response.StatusCode != 200 {
 			err = ErrorGitHubAccessDenied
@@ -97,14 +102,27 @@ func (c *GithubClient) GetTeam(name string, id int) (team *github.Team, err erro
 	}
 	// Exit with error
 
-	err = errors.New("No such team name or id could be found")
+	err = ErrorGitHubTeamNotFound
 	return
 }
 
-func (c *GithubClient) getUser(name string) (*github.User, error) {
+// GetUser - return profile of user {name}
+func (c *GithubClient) GetUser(name string) (user *github.User, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			user = nil
+			err = ErrorGitHubConnectionFailed
+		}
+	}()
+
 	user, response, err := c.client.Users.Get(context.Background(), name)
+	observe("users", response)
 
-	if response.StatusCode != 200 {
+	switch response.StatusCode {
+	case 200:
+	case 404:
+		return nil, ErrorGitHubNotFound
+	default:
 		return nil, ErrorGitHubAccessDenied
 	}
 
@@ -113,9 +131,10 @@ func (c *GithubClient) getUser(name string) (*github.User, error) {
 
 // IsTeamMember - check if {user} is a member of {team}
 func (c *GithubClient) IsTeamMember(user string, team *github.Team) (bool, error) {
-	result, _, err := c.client.Teams.GetTeamMembershipByID(
+	result, response, err := c.client.Teams.GetTeamMembershipByID(
 		context.Background(), *c.organizationId, *team.ID, user,
 	)
+	observe("team_membership", response)
 	if result != nil {
 		return true, err
 	}
@@ -139,11 +158,12 @@ func (c *GithubClient) GetKeys(userName string) (keys []*github.Key, err error)
 	logger := log.WithFields(log.Fields{"class": "GithubClient", "method": "Get"})
 
 	var opt = &github.ListOptions{
-		PerPage: viper.GetInt("github_api_max_page_size"),
+		PerPage: c.pageSize,
 	}
 
 	for {
 		items, response, localErr := c.client.Users.ListKeys(context.Background(), userName, opt)
+		observe("user_keys", response)
 
 		logger.Debugf("Response: %v", response)
 		logger.Debugf("Response.StatusCode: %v", response.StatusCode)
@@ -184,7 +204,7 @@ func (c *GithubClient) GetTeamMembers(team *github.Team) (users []*github.User,
 
 	var opt = &github.TeamListTeamMembersOptions{
 		ListOptions: github.ListOptions{
-			PerPage: viper.GetInt("github_api_max_page_size"),
+			PerPage: c.pageSize,
 		},
 	}
 
@@ -192,6 +212,7 @@ func (c *GithubClient) GetTeamMembers(team *github.Team) (users []*github.User,
 		members, resp, localErr := c.client.Teams.ListTeamMembersByID(
 			context.Background(), *c.organizationId, *team.ID, opt,
 		)
+		observe("team_members", resp)
 		if resp.StatusCode != 200 {
 			return nil, ErrorGitHubAccessDenied
 		}
@@ -211,12 +232,46 @@ func (c *GithubClient) GetTeamMembers(team *github.Team) (users []*github.User,
 	return
 }
 
+// RateLimit - return core API rate limit status of the token, the call itself does not count against the limit
+func (c *GithubClient) RateLimit() (rate *github.Rate, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			rate = nil
+			err = ErrorGitHubConnectionFailed
+		}
+	}()
+
+	limits, response, err := c.client.RateLimits(context.Background())
+	observe("rate_limit", response)
+	if response == nil {
+		return nil, ErrorGitHubConnectionFailed
+	}
+	if response.StatusCode != 200 {
+		return nil, ErrorGitHubAccessDenied
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	metrics.GithubRateLimitRemaining.Set(float64(limits.Core.Remaining))
+	return limits.Core, nil
+}
+
+// SetPageSize - set items requested per page of lists, default is used when {size} is not positive
+func (c *GithubClient) SetPageSize(size int) {
+	if size <= 0 {
+		size = GithubAPIMaxPageSizeDefault
+	}
+	c.pageSize = size
+}
+
 func (client *GithubClient) SetOrganizationID() error {
 	if client.organizationId != nil {
 		return nil
 	}
 
 	organization, response, err := client.client.Organizations.Get(context.Background(), client.owner)
+	observe("organizations", response)
 	if response != nil && response.StatusCode != 200 {
 		return ErrorGitHubAccessDenied
 	}
@@ -229,10 +284,24 @@ func (client *GithubClient) SetOrganizationID() error {
 	return err
 }
 
+// observe - record call of GitHub API {endpoint} and rate limit reported by {response}
+func observe(endpoint string, response *github.Response) {
+	if response == nil || response.Response == nil {
+		metrics.GithubAPICalls.WithLabelValues(endpoint, "error").Inc()
+		return
+	}
+
+	metrics.GithubAPICalls.WithLabelValues(endpoint, strconv.Itoa(response.StatusCode)).Inc()
+
+	if response.Rate.Limit > 0 {
+		metrics.GithubRateLimitRemaining.Set(float64(response.Rate.Remaining))
+	}
+}
+
 // NewGithubClient - constructor of GithubClient structure
 func NewGithubClient(token, owner string) *GithubClient {
 	c := oauth2.NewClient(context.Background(), newAccessToken(token))
-	client := &GithubClient{client: github.NewClient(c), owner: owner}
+	client := &GithubClient{client: github.NewClient(c), owner: owner, pageSize: GithubAPIMaxPageSizeDefault}
 	err := client.SetOrganizationID()
 	if err != nil {
 		log.Info("GitHub Client initialization failed. Error: ", err)
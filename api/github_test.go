@@ -39,14 +39,14 @@ var _ = Describe("GithubClient", func() {
 		validTeamName = viper.GetString("github_team")
 		validTeamID = viper.GetInt("github_team_id")
 		validUser = viper.GetString("github_user")
-		// Set max page size to 1 for test pagination code
-		viper.Set("github_api_max_page_size", 1)
 	})
 
 	Describe("getTeam()", func() {
 		Context("call with valid token, org, team name and team id ", func() {
 			It("should return nil error and valid team", func() {
 				c := NewGithubClient(validToken, validOrg)
+				// Set max page size to 1 for test pagination code
+				c.SetPageSize(1)
 				team, err := c.GetTeam(validTeamName, validTeamID)
 
 				Expect(err).To(BeNil())
@@ -60,6 +60,8 @@ var _ = Describe("GithubClient", func() {
 		Context("call with invalid team name AND valid token, org, team id", func() {
 			It("should return nil error and valid team", func() {
 				c := NewGithubClient(validToken, validOrg)
+				// Set max page size to 1 for test pagination code
+				c.SetPageSize(1)
 				team, err := c.GetTeam("dasdasd", validTeamID)
 
 				Expect(err).To(BeNil())
@@ -73,6 +75,8 @@ var _ = Describe("GithubClient", func() {
 		Context("call with invalid team name && team id AND valid token, org", func() {
 			It("should return valid error and nil team", func() {
 				c := NewGithubClient(validToken, validOrg)
+				// Set max page size to 1 for test pagination code
+				c.SetPageSize(1)
 				team, err := c.GetTeam("dasdasd", 0)
 
 				Expect(err).NotTo(BeNil())
@@ -112,6 +116,8 @@ var _ = Describe("GithubClient", func() {
 		Context("call with user that is member of the team", func() {
 			It("should return nil error and true value", func() {
 				c := NewGithubClient(validToken, validOrg)
+				// Set max page size to 1 for test pagination code
+				c.SetPageSize(1)
 				team, _ := c.GetTeam(validTeamName, validTeamID)
 				isMember, err := c.IsTeamMember(validUser, team)
 
@@ -124,6 +130,8 @@ var _ = Describe("GithubClient", func() {
 		Context("call with user that is not member of the team", func() {
 			It("should return nil error and false value", func() {
 				c := NewGithubClient(validToken, validOrg)
+				// Set max page size to 1 for test pagination code
+				c.SetPageSize(1)
 				team, _ := c.GetTeam(validTeamName, validTeamID)
 				isMember, err := c.IsTeamMember("dasda", team)
 
@@ -139,7 +147,9 @@ var _ = Describe("GithubClient", func() {
 		Context("call with valid user", func() {
 			It("should return nil error and not nil user", func() {
 				c := NewGithubClient(validToken, validOrg)
-				user, err := c.getUser(validUser)
+				// Set max page size to 1 for test pagination code
+				c.SetPageSize(1)
+				user, err := c.GetUser(validUser)
 
 				Expect(err).To(BeNil())
 
@@ -150,7 +160,9 @@ var _ = Describe("GithubClient", func() {
 		Context("call with invalid user", func() {
 			It("should return error and nil user", func() {
 				c := NewGithubClient(validToken, validOrg)
-				user, err := c.getUser("dasdddds232dasdas")
+				// Set max page size to 1 for test pagination code
+				c.SetPageSize(1)
+				user, err := c.GetUser("dasdddds232dasdas")
 
 				Expect(err).NotTo(BeNil())
 
@@ -164,7 +176,9 @@ var _ = Describe("GithubClient", func() {
 		Context("call with valid user", func() {
 			It("should return nil error and no empty list of keys", func() {
 				c := NewGithubClient(validToken, validOrg)
-				user, _ := c.getUser(validUser)
+				// Set max page size to 1 for test pagination code
+				c.SetPageSize(1)
+				user, _ := c.GetUser(validUser)
 				keys, err := c.GetKeys(*user.Login)
 
 				Expect(err).To(BeNil())
@@ -178,6 +192,8 @@ var _ = Describe("GithubClient", func() {
 		Context("call with valid team", func() {
 			It("should return nil error and no empty list of members", func() {
 				c := NewGithubClient(validToken, validOrg)
+				// Set max page size to 1 for test pagination code
+				c.SetPageSize(1)
 				team, _ := c.GetTeam(validTeamName, validTeamID)
 
 				members, err := c.GetTeamMembers(team)
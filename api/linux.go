@@ -20,31 +20,51 @@ package api
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"io"
 	"os/exec"
 	"strings"
 
 	log "github.com/sirupsen/logrus"
+	"github.com/terjekv/github-authorized-keys/config"
 
 	"syscall"
 
 	"github.com/valyala/fasttemplate"
 )
 
+// ErrorEmptyTemplate - returned when command template has no arguments
+var ErrorEmptyTemplate = errors.New("Command template is empty")
+
 // Linux - linux os with root dir
 type Linux struct {
-	root string
+	root      string
+	backend   string
+	profile   string
+	templates map[string][]string
 }
 
 // NewLinux - creates object allow interact with operating system
 //
 // rootDir - Path to directory contains linux root.
 //
-// Returns: OS object
+// Returns: OS object that manages users with commands of profile detected from os-release
 func NewLinux(rootDir string) Linux {
 	return Linux{root: rootDir}
 }
 
+// NewConfiguredLinux - creates object allow interact with operating system under root directory of {cfg}
+// using its users backend, profile and command templates
+func NewConfiguredLinux(cfg config.Config) Linux {
+	return Linux{root: cfg.Root, backend: cfg.UserBackend, profile: cfg.LinuxProfile, templates: cfg.Templates}
+}
+
 func (linux *Linux) getEntity(database, key string) ([]string, error) {
+	if linux.isNative() {
+		return linux.nativeEntity(database, key)
+	}
+
 	getent := linux.Command("getent", database, key)
 
 	var b2 bytes.Buffer
@@ -85,10 +105,37 @@ func (linux *Linux) Command(name string, params ...string) *exec.Cmd {
 }
 
 // TemplateCommand - creates command based on template and args with placeholders.
-func (linux *Linux) TemplateCommand(template string, args map[string]interface{}) *exec.Cmd {
+// Template is a list of arguments, each placeholder expands within its argument, so values never split into extra arguments.
+// Values starting with "-" are rejected, so they could not be taken as options.
+func (linux *Linux) TemplateCommand(template []string, args map[string]interface{}) (*exec.Cmd, error) {
 	logger := log.WithFields(log.Fields{"class": "Linux", "method": "TemplateCommand"})
-	t := fasttemplate.New(template, "{", "}")
-	cmd := strings.Split(t.ExecuteString(args), " ")
-	logger.Debugf("Command:  %v", cmd)
-	return linux.Command(cmd[0], cmd[1:]...)
+
+	if len(template) == 0 {
+		return nil, ErrorEmptyTemplate
+	}
+
+	cmd := make([]string, 0, len(template))
+	for _, argument := range template {
+		var substituteErr error
+		value := fasttemplate.New(argument, "{", "}").ExecuteFuncString(func(w io.Writer, tag string) (int, error) {
+			value, ok := args[tag]
+			if !ok {
+				substituteErr = fmt.Errorf("placeholder {%v} has no value", tag)
+				return 0, nil
+			}
+			text := fmt.Sprint(value)
+			if strings.HasPrefix(text, "-") {
+				substituteErr = fmt.Errorf("value %q of placeholder {%v} could not start with \"-\"", text, tag)
+				return 0, nil
+			}
+			return w.Write([]byte(text))
+		})
+		if substituteErr != nil {
+			return nil, substituteErr
+		}
+		cmd = append(cmd, value)
+	}
+
+	logger.Debugf("Command:  %q", cmd)
+	return linux.Command(cmd[0], cmd[1:]...), nil
 }
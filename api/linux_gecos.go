@@ -0,0 +1,102 @@
+/*
+ * Github Authorized Keys - Use GitHub teams to manage system user accounts and authorized_keys
+ *
+ * Copyright 2016 Cloud Posse, LLC <hello@cloudposse.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"fmt"
+	"os/user"
+	"strings"
+	"unicode"
+)
+
+// Gecos - return comment field describing GitHub user with display {name}, {login} and public {email},
+// e.g. "Jane Doe (janedoe) <jane@example.com>"
+func Gecos(name, login, email string) string {
+	gecos := login
+	if name = sanitizeGecos(name); name != "" && name != login {
+		gecos = name + " (" + login + ")"
+	}
+	if email = sanitizeGecos(email); email != "" {
+		gecos += " <" + email + ">"
+	}
+	return gecos
+}
+
+// sanitizeGecos - drop characters that separate passwd fields or GECOS subfields, and control characters.
+// Leading "-" is dropped as well, so the value could never be taken as option of user commands.
+func sanitizeGecos(value string) string {
+	value = strings.Map(func(r rune) rune {
+		if r == ':' || r == ',' || unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, value)
+	return strings.TrimLeft(strings.Join(strings.Fields(value), " "), "- ")
+}
+
+// UserGecos - return comment field of user {userName}
+func (linux *Linux) UserGecos(userName string) string {
+	userInfo, err := linux.getEntity("passwd", userName)
+
+	if err != nil || len(userInfo) != countOfColumnsInPasswd {
+		return ""
+	}
+
+	return userInfo[dataColumnNumberInPasswd]
+}
+
+// UserSetGecos - set comment field of user {userName} to {gecos}
+func (linux *Linux) UserSetGecos(userName, gecos string) error {
+	if linux.isNative() {
+		return linux.nativeUserSetGecos(userName, gecos)
+	}
+
+	cmd, err := linux.TemplateCommand(linux.Template("linux_user_set_gecos_tpl"),
+		map[string]interface{}{"username": userName, "gecos": gecos})
+	if err != nil {
+		return err
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%v: %v", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// nativeUserSetGecos - set comment field of user {userName} in passwd file
+func (linux *Linux) nativeUserSetGecos(userName, gecos string) error {
+	unlock, err := linux.lockPasswd()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	passwd, err := linux.readDatabase(passwdFile)
+	if err != nil {
+		return err
+	}
+
+	userInfo, index := passwd.find(nameColumnNumberInPasswd, userName)
+	if userInfo == nil {
+		return user.UnknownUserError(userName)
+	}
+	userInfo[dataColumnNumberInPasswd] = gecos
+	passwd.set(index, userInfo)
+
+	return linux.writeDatabases(passwd)
+}
@@ -0,0 +1,84 @@
+/*
+ * Github Authorized Keys - Use GitHub teams to manage system user accounts and authorized_keys
+ *
+ * Copyright 2016 Cloud Posse, LLC <hello@cloudposse.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/terjekv/github-authorized-keys/config"
+	model "github.com/terjekv/github-authorized-keys/model/linux"
+)
+
+var _ = Describe("Gecos()", func() {
+	DescribeTable("should describe GitHub user",
+		func(name, login, email, gecos string) {
+			Expect(Gecos(name, login, email)).To(Equal(gecos))
+		},
+		Entry("with name and email", "Jane Doe", "janedoe", "jane@example.com", "Jane Doe (janedoe) <jane@example.com>"),
+		Entry("without name", "", "janedoe", "jane@example.com", "janedoe <jane@example.com>"),
+		Entry("without email", "Jane Doe", "janedoe", "", "Jane Doe (janedoe)"),
+		Entry("with name equal to login", "janedoe", "janedoe", "", "janedoe"),
+		Entry("with separators in name", "Doe, Jane: PhD\n", "janedoe", "", "Doe Jane PhD (janedoe)"),
+		Entry("with option-like name", "--jane", "janedoe", "", "jane (janedoe)"),
+	)
+})
+
+var _ = Describe("UserCreate() with GECOS", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "gak-gecos")
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("should pass GECOS to add command", func() {
+		linux := NewConfiguredLinux(config.Config{Root: "/", Templates: map[string][]string{
+			"linux_user_add_tpl":       {"touch", filepath.Join(dir, "{username}-{gecos}")},
+			"linux_user_set_gecos_tpl": {"true"},
+		}})
+
+		user := model.NewUser("gak-test-carol", "", []string{}, "/bin/sh")
+		user.SetGecos("Carol (carol)")
+		Expect(linux.UserCreate(user)).To(BeNil())
+		Expect(filepath.Join(dir, "gak-test-carol-Carol (carol)")).To(BeAnExistingFile())
+	})
+
+	It("should add user to groups when GECOS could not be set", func() {
+		linux := NewConfiguredLinux(config.Config{Root: "/", Templates: map[string][]string{
+			"linux_user_add_tpl":          {"true", "{username}"},
+			"linux_user_set_gecos_tpl":    {"false", "{gecos}"},
+			"linux_user_add_to_group_tpl": {"touch", filepath.Join(dir, "{username}-{group}")},
+		}})
+
+		user := model.NewUser("gak-test-carol", "", []string{"users"}, "/bin/sh")
+		user.SetGecos("Carol (carol)")
+		Expect(linux.UserCreate(user)).To(BeNil())
+		Expect(filepath.Join(dir, "gak-test-carol-users")).To(BeAnExistingFile())
+	})
+})
@@ -20,6 +20,7 @@ package api
 
 import (
 	"errors"
+	"fmt"
 	"os/user"
 )
 
@@ -90,3 +91,39 @@ func (linux *Linux) groupExistsByID(groupID string) bool {
 	group, _ := linux.groupLookupByID(groupID)
 	return group != nil
 }
+
+// groupCreate - create group {groupName} with gid {groupID}
+func (linux *Linux) groupCreate(groupName, groupID string) error {
+	cmd, err := linux.TemplateCommand(linux.Template("linux_group_add_tpl"),
+		map[string]interface{}{"group": groupName, "gid": groupID})
+	if err != nil {
+		return err
+	}
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		fmt.Printf("%v\n", string(out))
+		return err
+	}
+
+	fmt.Printf("Created group %v\n", groupName)
+	return nil
+}
+
+// groupDelete - delete group {groupName}
+func (linux *Linux) groupDelete(groupName string) error {
+	cmd, err := linux.TemplateCommand(linux.Template("linux_group_del_tpl"),
+		map[string]interface{}{"group": groupName})
+	if err != nil {
+		return err
+	}
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		fmt.Printf("%v\n", string(out))
+		return err
+	}
+
+	fmt.Printf("Deleted group %v\n", groupName)
+	return nil
+}
@@ -0,0 +1,119 @@
+/*
+ * Github Authorized Keys - Use GitHub teams to manage system user accounts and authorized_keys
+ *
+ * Copyright 2016 Cloud Posse, LLC <hello@cloudposse.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	model "github.com/terjekv/github-authorized-keys/model/linux"
+)
+
+const (
+	// UIDCollisionProbe - on collision try following ids of the range
+	UIDCollisionProbe = model.UIDCollisionProbe
+
+	// UIDCollisionAuto - on collision let the user backend pick any free id
+	UIDCollisionAuto = model.UIDCollisionAuto
+
+	// UIDCollisionSkip - on collision do not create the user
+	UIDCollisionSkip = model.UIDCollisionSkip
+)
+
+// ErrorIDCollision - returned when derived uid is already used and could not be replaced
+var ErrorIDCollision = errors.New("Derived uid is already used")
+
+// DeterministicID - derive id from GitHub user id {githubID} within range [{base}, {base}+{span})
+func DeterministicID(githubID int64, base, span uint64) uint64 {
+	return base + uint64(githubID)%span
+}
+
+// enumerateDatabase - read all rows of database {name}: the file under root for native backend,
+// one getent dump otherwise, so entries from other NSS sources are seen as well
+func (linux *Linux) enumerateDatabase(name string) (*database, error) {
+	if linux.isNative() {
+		return linux.readDatabase("/etc/" + name)
+	}
+
+	var output bytes.Buffer
+	getent := linux.Command("getent", name)
+	getent.Stdout = &output
+	if err := getent.Run(); err != nil {
+		return nil, err
+	}
+
+	return &database{path: "/etc/" + name, lines: strings.Split(strings.TrimRight(output.String(), "\n"), "\n")}, nil
+}
+
+// usedIDs - return set of ids used either as uid or as gid, such ids could not be uid and personal group gid
+func (linux *Linux) usedIDs() (map[uint64]bool, error) {
+	passwd, err := linux.enumerateDatabase("passwd")
+	if err != nil {
+		return nil, err
+	}
+	group, err := linux.enumerateDatabase("group")
+	if err != nil {
+		return nil, err
+	}
+
+	used := passwd.ids(uidColumnNumberInPasswd)
+	for id := range group.ids(gidColumnNumberInGroup) {
+		used[id] = true
+	}
+	return used, nil
+}
+
+// AssignID - return uid derived from GitHub user id {githubID}, the same on every host.
+// When derived id is used, {collision} strategy applies: probe following ids of the range, return empty string so
+// the user backend picks id (auto), or return ErrorIDCollision (skip).
+func (linux *Linux) AssignID(githubID int64, base, span uint64, collision string) (string, error) {
+	logger := log.WithFields(log.Fields{"class": "Linux", "method": "AssignID"})
+
+	// passwd and group are read once, candidates are probed in memory
+	used, err := linux.usedIDs()
+	if err != nil {
+		return "", err
+	}
+
+	id := DeterministicID(githubID, base, span)
+	if !used[id] {
+		return strconv.FormatUint(id, 10), nil
+	}
+
+	switch collision {
+	case UIDCollisionAuto:
+		logger.Warnf("Derived uid %v is already used, let user backend pick uid", id)
+		return "", nil
+
+	case UIDCollisionProbe:
+		for step := uint64(1); step < span; step++ {
+			candidate := DeterministicID(githubID+int64(step), base, span)
+			if !used[candidate] {
+				logger.Warnf("Derived uid %v is already used, use %v", id, candidate)
+				return strconv.FormatUint(candidate, 10), nil
+			}
+		}
+	}
+
+	logger.Errorf("Derived uid %v is already used", id)
+	return "", ErrorIDCollision
+}
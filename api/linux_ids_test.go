@@ -0,0 +1,156 @@
+/*
+ * Github Authorized Keys - Use GitHub teams to manage system user accounts and authorized_keys
+ *
+ * Copyright 2016 Cloud Posse, LLC <hello@cloudposse.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/terjekv/github-authorized-keys/config"
+	model "github.com/terjekv/github-authorized-keys/model/linux"
+)
+
+var _ = Describe("Linux ids", func() {
+	var (
+		root  string
+		linux Linux
+	)
+
+	BeforeEach(func() {
+		var err error
+		root, err = ioutil.TempDir("", "gak-ids")
+		Expect(err).To(BeNil())
+
+		Expect(os.MkdirAll(filepath.Join(root, "etc"), 0755)).To(BeNil())
+		files := map[string]string{
+			// 100042 is used as uid, 100043 as gid
+			"etc/passwd": "root:x:0:0:root:/root:/bin/bash\nalice:x:100042:100:::/bin/bash\n",
+			"etc/group":  "root:x:0:\nusers:x:100:\nbob:x:100043:\n",
+		}
+		for name, content := range files {
+			Expect(ioutil.WriteFile(filepath.Join(root, name), []byte(content), 0644)).To(BeNil())
+		}
+
+		linux = NewConfiguredLinux(config.Config{Root: root, UserBackend: UserBackendNative})
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(root)
+	})
+
+	Describe("DeterministicID()", func() {
+		It("should map GitHub id into range", func() {
+			Expect(DeterministicID(41, 100000, 1000)).To(Equal(uint64(100041)))
+			Expect(DeterministicID(1041, 100000, 1000)).To(Equal(uint64(100041)))
+			Expect(DeterministicID(999, 100000, 1000)).To(Equal(uint64(100999)))
+		})
+	})
+
+	Describe("AssignID()", func() {
+		Context("call with free id", func() {
+			It("should return derived id", func() {
+				Expect(linux.AssignID(41, 100000, 1000, UIDCollisionSkip)).To(Equal("100041"))
+			})
+		})
+
+		Context("call with id used as uid and following as gid", func() {
+			It("should probe next free id", func() {
+				Expect(linux.AssignID(42, 100000, 1000, UIDCollisionProbe)).To(Equal("100044"))
+			})
+
+			It("should let backend pick id", func() {
+				Expect(linux.AssignID(42, 100000, 1000, UIDCollisionAuto)).To(Equal(""))
+			})
+
+			It("should return error", func() {
+				_, err := linux.AssignID(42, 100000, 1000, UIDCollisionSkip)
+				Expect(err).To(Equal(ErrorIDCollision))
+			})
+		})
+
+		Context("call with whole range used", func() {
+			It("should return error", func() {
+				_, err := linux.AssignID(42, 100042, 2, UIDCollisionProbe)
+				Expect(err).To(Equal(ErrorIDCollision))
+			})
+		})
+
+		Context("call with unreadable group database", func() {
+			It("should return error instead of treating ids as free", func() {
+				Expect(os.Remove(filepath.Join(root, "etc/group"))).To(BeNil())
+				_, err := linux.AssignID(41, 100000, 1000, UIDCollisionProbe)
+				Expect(os.IsNotExist(err)).To(BeTrue())
+			})
+		})
+	})
+
+	Describe("usedIDs()", func() {
+		It("should collect uids and gids", func() {
+			Expect(linux.usedIDs()).To(Equal(map[uint64]bool{0: true, 100: true, 100042: true, 100043: true}))
+		})
+
+		It("should collect ids from single getent dump with command backend", func() {
+			if _, err := exec.LookPath("getent"); err != nil {
+				Skip("getent is not available")
+			}
+			linux = NewConfiguredLinux(config.Config{Root: "/", UserBackend: UserBackendCommand})
+			used, err := linux.usedIDs()
+			Expect(err).To(BeNil())
+			Expect(used).To(HaveKey(uint64(0)))
+		})
+	})
+
+	Describe("UserCreate()", func() {
+		It("should create user and personal group with derived id", func() {
+			if os.Geteuid() != 0 {
+				Skip("home directory ownership could be set only by root")
+			}
+
+			user := model.NewUser("carol", "", []string{}, "/bin/bash")
+			user.SetUid("100041")
+			Expect(linux.UserCreate(user)).To(BeNil())
+
+			passwd, _ := ioutil.ReadFile(filepath.Join(root, "etc/passwd"))
+			group, _ := ioutil.ReadFile(filepath.Join(root, "etc/group"))
+			Expect(string(passwd)).To(ContainSubstring("carol:x:100041:100041::/home/carol:/bin/bash\n"))
+			Expect(string(group)).To(ContainSubstring("carol:x:100041:\n"))
+		})
+
+		Context("call with failing user add command", func() {
+			It("should delete personal group", func() {
+				linux = NewConfiguredLinux(config.Config{Root: "/", UserBackend: UserBackendCommand, Templates: map[string][]string{
+					"linux_group_add_tpl":         {"touch", filepath.Join(root, "added-{group}")},
+					"linux_group_del_tpl":         {"touch", filepath.Join(root, "deleted-{group}")},
+					"linux_user_add_with_uid_tpl": {"false", "{username}"},
+				}})
+
+				user := model.NewUser("carol", "", []string{}, "/bin/bash")
+				user.SetUid("100041")
+				Expect(linux.UserCreate(user)).NotTo(BeNil())
+
+				Expect(filepath.Join(root, "added-carol")).To(BeAnExistingFile())
+				Expect(filepath.Join(root, "deleted-carol")).To(BeAnExistingFile())
+			})
+		})
+	})
+})
@@ -0,0 +1,749 @@
+/*
+ * Github Authorized Keys - Use GitHub teams to manage system user accounts and authorized_keys
+ *
+ * Copyright 2016 Cloud Posse, LLC <hello@cloudposse.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/terjekv/github-authorized-keys/metrics"
+	"github.com/terjekv/github-authorized-keys/model/linux"
+)
+
+const (
+	// UserBackendCommand - manage users with command templates (adduser, useradd, ...)
+	UserBackendCommand = linux.UserBackendCommand
+
+	// UserBackendNative - manage users by editing passwd, shadow, group and gshadow files directly
+	UserBackendNative = linux.UserBackendNative
+)
+
+const (
+	passwdFile  = "/etc/passwd"
+	shadowFile  = "/etc/shadow"
+	groupFile   = "/etc/group"
+	gshadowFile = "/etc/gshadow"
+
+	// Lock file shared with shadow utils, see lckpwdf(3)
+	passwdLockFile = "/etc/.pwd.lock"
+
+	// Same timeout as lckpwdf(3) uses
+	passwdLockTimeout = 15 * time.Second
+
+	loginDefsFile = "/etc/login.defs"
+	skelDir       = "/etc/skel"
+	homeBaseDir   = "/home"
+
+	// Disabled password, key based ssh login is still allowed
+	disabledPassword = "*"
+
+	// Gshadow file contains group members in 3 column
+	usersColumnNumberInGshadow = 3
+)
+
+var (
+	// ErrorPasswdLocked - returned when passwd files are locked by another process for too long
+	ErrorPasswdLocked = errors.New("Passwd files are locked by another process")
+
+	// ErrorNoFreeID - returned when there is no free uid or gid in configured range
+	ErrorNoFreeID = errors.New("No free id in range")
+)
+
+// isNative - check if users are managed by native backend
+func (linux *Linux) isNative() bool {
+	return linux.backend == UserBackendNative
+}
+
+// database - rows of colon separated file like /etc/passwd, comments and empty lines are kept as is
+type database struct {
+	path  string
+	lines []string
+}
+
+func (linux *Linux) readDatabase(filePath string) (*database, error) {
+	content, err := ioutil.ReadFile(linux.applyChroot(filePath))
+	if err != nil {
+		return nil, err
+	}
+
+	db := &database{path: filePath}
+	scanner := bufio.NewScanner(strings.NewReader(string(content)))
+	for scanner.Scan() {
+		db.lines = append(db.lines, scanner.Text())
+	}
+	return db, scanner.Err()
+}
+
+// readOptionalDatabase - read database that could be missing on some distributions (e.g. gshadow on Alpine)
+func (linux *Linux) readOptionalDatabase(filePath string) (*database, error) {
+	db, err := linux.readDatabase(filePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return db, err
+}
+
+func isRow(line string) bool {
+	return line != "" && !strings.HasPrefix(line, "#")
+}
+
+// find - return columns and index of row which {column} equals {value}
+func (db *database) find(column int, value string) ([]string, int) {
+	for i, line := range db.lines {
+		if !isRow(line) {
+			continue
+		}
+		columns := strings.Split(line, ":")
+		if len(columns) > column && columns[column] == value {
+			return columns, i
+		}
+	}
+	return nil, -1
+}
+
+// ids - return set of numeric values in {column}
+func (db *database) ids(column int) map[uint64]bool {
+	ids := map[uint64]bool{}
+	for _, line := range db.lines {
+		if !isRow(line) {
+			continue
+		}
+		columns := strings.Split(line, ":")
+		if len(columns) > column {
+			if id, err := strconv.ParseUint(columns[column], 10, 32); err == nil {
+				ids[id] = true
+			}
+		}
+	}
+	return ids
+}
+
+func (db *database) append(columns ...string) {
+	db.lines = append(db.lines, strings.Join(columns, ":"))
+}
+
+func (db *database) set(index int, columns []string) {
+	db.lines[index] = strings.Join(columns, ":")
+}
+
+// remove - remove rows which column 0 equals {name}
+func (db *database) remove(name string) {
+	lines := make([]string, 0, len(db.lines))
+	for _, line := range db.lines {
+		if isRow(line) && strings.SplitN(line, ":", 2)[0] == name {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	db.lines = lines
+}
+
+// rename - rename row which column 0 equals {oldName}
+func (db *database) rename(oldName, newName string) {
+	if columns, index := db.find(nameColumnNumberInPasswd, oldName); columns != nil {
+		columns[nameColumnNumberInPasswd] = newName
+		db.set(index, columns)
+	}
+}
+
+// addMember - add {name} to comma separated members list in {column} of row {group}
+func (db *database) addMember(group string, column int, name string) bool {
+	columns, index := db.find(nameColumnNumberInGroup, group)
+	if index < 0 {
+		return false
+	}
+	for len(columns) <= column {
+		columns = append(columns, "")
+	}
+
+	members := splitMembers(columns[column])
+	for _, member := range members {
+		if member == name {
+			return true
+		}
+	}
+	columns[column] = strings.Join(append(members, name), ",")
+	db.set(index, columns)
+	return true
+}
+
+// removeMember - remove {name} from members lists in {column} of all rows
+func (db *database) removeMember(column int, name string) {
+	for i, line := range db.lines {
+		if !isRow(line) {
+			continue
+		}
+		columns := strings.Split(line, ":")
+		if len(columns) <= column {
+			continue
+		}
+		members := []string{}
+		for _, member := range splitMembers(columns[column]) {
+			if member != name {
+				members = append(members, member)
+			}
+		}
+		columns[column] = strings.Join(members, ",")
+		db.set(i, columns)
+	}
+}
+
+// renameMember - replace {oldName} with {newName} in members lists in {column} of all rows
+func (db *database) renameMember(column int, oldName, newName string) {
+	for i, line := range db.lines {
+		if !isRow(line) {
+			continue
+		}
+		columns := strings.Split(line, ":")
+		if len(columns) <= column {
+			continue
+		}
+		members := splitMembers(columns[column])
+		for j, member := range members {
+			if member == oldName {
+				members[j] = newName
+			}
+		}
+		columns[column] = strings.Join(members, ",")
+		db.set(i, columns)
+	}
+}
+
+func splitMembers(value string) []string {
+	if value == "" {
+		return []string{}
+	}
+	return strings.Split(value, ",")
+}
+
+// renameFile - rename used to replace database files, replaced in tests to simulate failures
+var renameFile = os.Rename
+
+// stagedDatabase - database file with new content written to temporary file, not yet renamed over target
+type stagedDatabase struct {
+	target   string
+	temp     string
+	previous []byte
+	info     os.FileInfo
+}
+
+// writeDatabases - replace all database files or none of them keeping their modes and owners: new content is written
+// to temporary files first and renamed over targets once all are written, targets already replaced are restored if
+// a rename fails. Previous content is kept in files with "-" suffix
+func (linux *Linux) writeDatabases(dbs ...*database) error {
+	logger := log.WithFields(log.Fields{"class": "Linux", "method": "writeDatabases"})
+
+	staged := []stagedDatabase{}
+	defer func() {
+		for _, file := range staged {
+			os.Remove(file.temp)
+		}
+	}()
+
+	for _, db := range dbs {
+		if db == nil {
+			continue
+		}
+		target := linux.applyChroot(db.path)
+
+		info, err := os.Stat(target)
+		if err != nil {
+			return err
+		}
+		previous, err := ioutil.ReadFile(target)
+		if err != nil {
+			return err
+		}
+
+		content := strings.Join(db.lines, "\n") + "\n"
+		temp, err := writeTempFile(target, []byte(content), info)
+		if err != nil {
+			return err
+		}
+		staged = append(staged, stagedDatabase{target: target, temp: temp, previous: previous, info: info})
+	}
+
+	for _, file := range staged {
+		if err := writeFileAtomic(file.target+"-", file.previous, file.info); err != nil {
+			return err
+		}
+	}
+
+	for index, file := range staged {
+		if err := renameFile(file.temp, file.target); err != nil {
+			for _, replaced := range staged[:index] {
+				if restoreErr := writeFileAtomic(replaced.target, replaced.previous, replaced.info); restoreErr != nil {
+					logger.Errorf("Unable to restore %v, previous content is kept in %v-: %v", replaced.target, replaced.target, restoreErr)
+				}
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeFileAtomic - write {content} to temporary file in the same directory and rename it to {target}
+func writeFileAtomic(target string, content []byte, info os.FileInfo) error {
+	temp, err := writeTempFile(target, content, info)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp)
+
+	return renameFile(temp, target)
+}
+
+// writeTempFile - write {content} with mode and owner of {info} to temporary file next to {target}, return its path
+func writeTempFile(target string, content []byte, info os.FileInfo) (string, error) {
+	file, err := ioutil.TempFile(filepath.Dir(target), "."+filepath.Base(target)+".")
+	if err != nil {
+		return "", err
+	}
+
+	fail := func(err error) (string, error) {
+		file.Close()
+		os.Remove(file.Name())
+		return "", err
+	}
+
+	if _, err := file.Write(content); err != nil {
+		return fail(err)
+	}
+	if err := file.Chmod(info.Mode().Perm()); err != nil {
+		return fail(err)
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		if err := file.Chown(int(stat.Uid), int(stat.Gid)); err != nil {
+			return fail(err)
+		}
+	}
+	if err := file.Sync(); err != nil {
+		return fail(err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+// lockPasswd - take the same lock as lckpwdf(3), so shadow utils do not modify files at the same time
+func (linux *Linux) lockPasswd() (func(), error) {
+	file, err := os.OpenFile(linux.applyChroot(passwdLockFile), os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	lock := syscall.Flock_t{Type: syscall.F_WRLCK, Whence: io.SeekStart}
+	deadline := time.Now().Add(passwdLockTimeout)
+	for {
+		err = syscall.FcntlFlock(file.Fd(), syscall.F_SETLK, &lock)
+		if err == nil {
+			break
+		}
+		if err != syscall.EAGAIN && err != syscall.EACCES {
+			file.Close()
+			return nil, err
+		}
+		if time.Now().After(deadline) {
+			file.Close()
+			return nil, ErrorPasswdLocked
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	return func() {
+		unlock := syscall.Flock_t{Type: syscall.F_UNLCK, Whence: io.SeekStart}
+		syscall.FcntlFlock(file.Fd(), syscall.F_SETLK, &unlock)
+		file.Close()
+	}, nil
+}
+
+// loginDefs - return integer {key} from login.defs or {fallback}
+func (linux *Linux) loginDefs(key string, fallback uint64) uint64 {
+	db, err := linux.readDatabase(loginDefsFile)
+	if err != nil {
+		return fallback
+	}
+	for _, line := range db.lines {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && fields[0] == key {
+			if value, err := strconv.ParseUint(fields[1], 10, 32); err == nil {
+				return value
+			}
+		}
+	}
+	return fallback
+}
+
+// freeID - return id after the highest used id in range [{min}, {max}], or lowest free id when the range end is reached
+func freeID(used map[uint64]bool, min, max uint64) (uint64, error) {
+	next := min
+	for id := range used {
+		if id >= min && id <= max && id >= next {
+			next = id + 1
+		}
+	}
+	if next <= max {
+		return next, nil
+	}
+	for id := min; id <= max; id++ {
+		if !used[id] {
+			return id, nil
+		}
+	}
+	return 0, ErrorNoFreeID
+}
+
+// nativeEntity - lookup row of {database} by name or numeric id like getent does, but only in files under root
+func (linux *Linux) nativeEntity(name, key string) ([]string, error) {
+	db, err := linux.readDatabase("/etc/" + name)
+	if err != nil {
+		return []string{}, err
+	}
+
+	columns, _ := db.find(nameColumnNumberInPasswd, key)
+	if columns == nil && name != "shadow" {
+		if _, err := strconv.ParseUint(key, 10, 32); err == nil {
+			// uid and gid are both stored in column 2 of passwd and group
+			columns, _ = db.find(uidColumnNumberInPasswd, key)
+		}
+	}
+	if columns == nil {
+		return []string{}, fmt.Errorf("%v not found in %v", key, name)
+	}
+	return columns, nil
+}
+
+// nativeUserCreate - create user {new} with private or given primary group and home directory from skel
+func (linux *Linux) nativeUserCreate(new linux.User) error {
+	logger := log.WithFields(log.Fields{"class": "Linux", "method": "nativeUserCreate"})
+
+	unlock, err := linux.lockPasswd()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	passwd, err := linux.readDatabase(passwdFile)
+	if err != nil {
+		return err
+	}
+	group, err := linux.readDatabase(groupFile)
+	if err != nil {
+		return err
+	}
+	shadow, err := linux.readOptionalDatabase(shadowFile)
+	if err != nil {
+		return err
+	}
+	gshadow, err := linux.readOptionalDatabase(gshadowFile)
+	if err != nil {
+		return err
+	}
+
+	if columns, _ := passwd.find(nameColumnNumberInPasswd, new.Name()); columns != nil {
+		return fmt.Errorf("user %v already exists", new.Name())
+	}
+
+	var uid uint64
+	if new.Uid() != "" {
+		if uid, err = strconv.ParseUint(new.Uid(), 10, 32); err != nil {
+			return err
+		}
+		if passwd.ids(uidColumnNumberInPasswd)[uid] {
+			return fmt.Errorf("uid %v is already used", uid)
+		}
+	} else {
+		uid, err = freeID(passwd.ids(uidColumnNumberInPasswd),
+			linux.loginDefs("UID_MIN", 1000), linux.loginDefs("UID_MAX", 60000))
+		if err != nil {
+			return err
+		}
+	}
+
+	gid := ""
+	if new.Gid() != "" && new.Uid() == "" {
+		if columns, _ := group.find(gidColumnNumberInGroup, new.Gid()); columns != nil {
+			gid = new.Gid()
+		} else {
+			logger.Warnf("Group with gid %v not found, create private group of user %v", new.Gid(), new.Name())
+		}
+	}
+
+	if gid == "" {
+		if columns, _ := group.find(nameColumnNumberInGroup, new.Name()); columns != nil {
+			return fmt.Errorf("group %v already exists", new.Name())
+		}
+
+		usedGids := group.ids(gidColumnNumberInGroup)
+		privateGid := uid
+		if usedGids[privateGid] && new.Uid() != "" {
+			return fmt.Errorf("gid %v of personal group is already used", privateGid)
+		}
+		if usedGids[privateGid] {
+			if privateGid, err = freeID(usedGids, linux.loginDefs("GID_MIN", 1000), linux.loginDefs("GID_MAX", 60000)); err != nil {
+				return err
+			}
+		}
+		gid = strconv.FormatUint(privateGid, 10)
+
+		group.append(new.Name(), "x", gid, "")
+		if gshadow != nil {
+			gshadow.append(new.Name(), "!", "", "")
+		}
+	}
+
+	for _, name := range new.Groups() {
+		if !group.addMember(name, usersColumnNumberInGroup, new.Name()) {
+			return user.UnknownGroupError(name)
+		}
+		if gshadow != nil {
+			gshadow.addMember(name, usersColumnNumberInGshadow, new.Name())
+		}
+	}
+
+	home := path.Join(homeBaseDir, new.Name())
+	passwd.append(new.Name(), "x", strconv.FormatUint(uid, 10), gid, new.Gecos(), home, new.Shell())
+	if shadow != nil {
+		days := strconv.FormatInt(time.Now().Unix()/(24*60*60), 10)
+		shadow.append(new.Name(), disabledPassword, days, "0", "99999", "7", "", "", "")
+	}
+
+	// Groups are renamed first, so passwd never references missing group
+	if err := linux.writeDatabases(group, gshadow, passwd, shadow); err != nil {
+		return err
+	}
+
+	gidValue, _ := strconv.Atoi(gid)
+	if err := linux.createHome(home, int(uid), gidValue); err != nil {
+		return err
+	}
+
+	fmt.Printf("Created user %v\n", new.Name())
+	metrics.UsersCreated.Inc()
+
+	for _, name := range new.Groups() {
+		fmt.Printf("Added user %v to group %v\n", new.Name(), name)
+	}
+
+	return nil
+}
+
+// createHome - create home directory {home} owned by user with content of skel directory
+func (linux *Linux) createHome(home string, uid, gid int) error {
+	target := linux.applyChroot(home)
+	if _, err := os.Stat(target); err == nil {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	if err := os.Mkdir(target, 0700); err != nil {
+		return err
+	}
+	if err := os.Chown(target, uid, gid); err != nil {
+		return err
+	}
+
+	skel := linux.applyChroot(skelDir)
+	if _, err := os.Stat(skel); err != nil {
+		return nil
+	}
+
+	return filepath.Walk(skel, func(source string, info os.FileInfo, err error) error {
+		if err != nil || source == skel {
+			return err
+		}
+
+		relative, _ := filepath.Rel(skel, source)
+		destination := filepath.Join(target, relative)
+
+		switch {
+		case info.IsDir():
+			err = os.Mkdir(destination, info.Mode().Perm())
+		case info.Mode()&os.ModeSymlink != 0:
+			var link string
+			if link, err = os.Readlink(source); err == nil {
+				err = os.Symlink(link, destination)
+			}
+		case info.Mode().IsRegular():
+			var content []byte
+			if content, err = ioutil.ReadFile(source); err == nil {
+				err = ioutil.WriteFile(destination, content, info.Mode().Perm())
+			}
+		default:
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return os.Lchown(destination, uid, gid)
+	})
+}
+
+// nativeUserDelete - remove user {name}, its private group and group memberships, home directory is kept
+func (linux *Linux) nativeUserDelete(name string) error {
+	unlock, err := linux.lockPasswd()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	passwd, err := linux.readDatabase(passwdFile)
+	if err != nil {
+		return err
+	}
+	group, err := linux.readDatabase(groupFile)
+	if err != nil {
+		return err
+	}
+	shadow, err := linux.readOptionalDatabase(shadowFile)
+	if err != nil {
+		return err
+	}
+	gshadow, err := linux.readOptionalDatabase(gshadowFile)
+	if err != nil {
+		return err
+	}
+
+	userInfo, _ := passwd.find(nameColumnNumberInPasswd, name)
+	if userInfo == nil {
+		return user.UnknownUserError(name)
+	}
+
+	passwd.remove(name)
+	if shadow != nil {
+		shadow.remove(name)
+	}
+
+	group.removeMember(usersColumnNumberInGroup, name)
+	if gshadow != nil {
+		gshadow.removeMember(usersColumnNumberInGshadow, name)
+	}
+
+	// Private group is removed only when no other user has it as primary group
+	privateGroup, _ := group.find(nameColumnNumberInGroup, name)
+	if privateGroup != nil && privateGroup[gidColumnNumberInGroup] == userInfo[gidColumnNumberInPasswd] {
+		if columns, _ := passwd.find(gidColumnNumberInPasswd, privateGroup[gidColumnNumberInGroup]); columns == nil {
+			group.remove(name)
+			if gshadow != nil {
+				gshadow.remove(name)
+			}
+		}
+	}
+
+	if err := linux.writeDatabases(passwd, shadow, group, gshadow); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// nativeUserRename - rename user {oldName} to {newName} in all databases and move its home directory
+func (linux *Linux) nativeUserRename(oldName, newName string) error {
+	unlock, err := linux.lockPasswd()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	passwd, err := linux.readDatabase(passwdFile)
+	if err != nil {
+		return err
+	}
+	group, err := linux.readDatabase(groupFile)
+	if err != nil {
+		return err
+	}
+	shadow, err := linux.readOptionalDatabase(shadowFile)
+	if err != nil {
+		return err
+	}
+	gshadow, err := linux.readOptionalDatabase(gshadowFile)
+	if err != nil {
+		return err
+	}
+
+	userInfo, index := passwd.find(nameColumnNumberInPasswd, oldName)
+	if userInfo == nil {
+		return user.UnknownUserError(oldName)
+	}
+	if columns, _ := passwd.find(nameColumnNumberInPasswd, newName); columns != nil {
+		return fmt.Errorf("user %v already exists", newName)
+	}
+
+	oldHome := userInfo[homeColumnNumberInPasswd]
+	newHome := renamedHome(oldHome, oldName, newName)
+	userInfo[nameColumnNumberInPasswd] = newName
+	userInfo[homeColumnNumberInPasswd] = newHome
+	passwd.set(index, userInfo)
+
+	if shadow != nil {
+		shadow.rename(oldName, newName)
+	}
+
+	group.renameMember(usersColumnNumberInGroup, oldName, newName)
+	if gshadow != nil {
+		gshadow.renameMember(usersColumnNumberInGshadow, oldName, newName)
+	}
+
+	// Personal group is renamed together with the user
+	if columns, _ := group.find(nameColumnNumberInGroup, oldName); columns != nil && columns[gidColumnNumberInGroup] == userInfo[gidColumnNumberInPasswd] {
+		if existing, _ := group.find(nameColumnNumberInGroup, newName); existing == nil {
+			group.rename(oldName, newName)
+			if gshadow != nil {
+				gshadow.rename(oldName, newName)
+			}
+		}
+	}
+
+	if newHome != oldHome {
+		if _, err := os.Stat(linux.applyChroot(newHome)); err == nil {
+			return fmt.Errorf("home directory %v already exists", newHome)
+		}
+	}
+
+	if err := linux.writeDatabases(group, gshadow, passwd, shadow); err != nil {
+		return err
+	}
+
+	if newHome != oldHome {
+		if err := os.Rename(linux.applyChroot(oldHome), linux.applyChroot(newHome)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	fmt.Printf("Renamed user %v to %v\n", oldName, newName)
+	return nil
+}
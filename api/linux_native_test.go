@@ -0,0 +1,232 @@
+/*
+ * Github Authorized Keys - Use GitHub teams to manage system user accounts and authorized_keys
+ *
+ * Copyright 2016 Cloud Posse, LLC <hello@cloudposse.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/terjekv/github-authorized-keys/config"
+	model "github.com/terjekv/github-authorized-keys/model/linux"
+)
+
+var _ = Describe("Linux native backend", func() {
+	var (
+		root  string
+		linux Linux
+	)
+
+	readFile := func(name string) string {
+		content, err := ioutil.ReadFile(filepath.Join(root, name))
+		Expect(err).To(BeNil())
+		return string(content)
+	}
+
+	BeforeEach(func() {
+		var err error
+		root, err = ioutil.TempDir("", "gak-native")
+		Expect(err).To(BeNil())
+
+		Expect(os.MkdirAll(filepath.Join(root, "etc/skel"), 0755)).To(BeNil())
+		files := map[string]string{
+			"etc/passwd":       "root:x:0:0:root:/root:/bin/bash\nalice:x:1000:1000::/home/alice:/bin/bash\n",
+			"etc/shadow":       "root:*:19000:0:99999:7:::\nalice:*:19000:0:99999:7:::\n",
+			"etc/group":        "root:x:0:\nwheel:x:10:alice\nusers:x:100:\nalice:x:1000:\nbob:x:1001:\n",
+			"etc/gshadow":      "root:::\nwheel:::alice\nusers:::\nalice:!::\nbob:!::\n",
+			"etc/login.defs":   "# test\nUID_MIN 1000\nUID_MAX 60000\n",
+			"etc/skel/.bashrc": "# bashrc\n",
+		}
+		for name, content := range files {
+			Expect(ioutil.WriteFile(filepath.Join(root, name), []byte(content), 0644)).To(BeNil())
+		}
+
+		linux = NewConfiguredLinux(config.Config{Root: root, UserBackend: UserBackendNative})
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(root)
+	})
+
+	Describe("UserExists()", func() {
+		It("should find users in files under root", func() {
+			Expect(linux.UserExists("alice")).To(BeTrue())
+			Expect(linux.UserExists("bob")).To(BeFalse())
+			Expect(linux.GroupExists("wheel")).To(BeTrue())
+			Expect(linux.groupExistsByID("100")).To(BeTrue())
+		})
+	})
+
+	Describe("UserCreate()", func() {
+		BeforeEach(func() {
+			if os.Geteuid() != 0 {
+				Skip("home directory ownership could be set only by root")
+			}
+		})
+
+		Context("call without GID", func() {
+			It("should create user with private group, groups and home", func() {
+				err := linux.UserCreate(model.NewUser("Carol", "", []string{"wheel", "users"}, "/bin/sh"))
+				Expect(err).To(BeNil())
+
+				Expect(readFile("etc/passwd")).To(ContainSubstring("carol:x:1001:1002::/home/carol:/bin/sh\n"))
+				Expect(readFile("etc/shadow")).To(MatchRegexp(`(?m)^carol:\*:\d+:0:99999:7:::$`))
+				Expect(readFile("etc/group")).To(ContainSubstring("wheel:x:10:alice,carol\n"))
+				Expect(readFile("etc/group")).To(ContainSubstring("users:x:100:carol\n"))
+				Expect(readFile("etc/group")).To(ContainSubstring("carol:x:1002:\n"))
+				Expect(readFile("etc/gshadow")).To(ContainSubstring("wheel:::alice,carol\n"))
+				Expect(readFile("etc/gshadow")).To(ContainSubstring("carol:!::\n"))
+				Expect(readFile("etc/passwd-")).NotTo(ContainSubstring("carol"))
+
+				Expect(readFile("home/carol/.bashrc")).To(Equal("# bashrc\n"))
+				info, err := os.Stat(filepath.Join(root, "home/carol"))
+				Expect(err).To(BeNil())
+				Expect(info.Mode().Perm()).To(Equal(os.FileMode(0700)))
+
+				Expect(linux.UserExists("carol")).To(BeTrue())
+				Expect(linux.userShell("carol")).To(Equal("/bin/sh"))
+				Expect(linux.UserStatus("carol")).To(Equal(UserStatusPresent))
+			})
+		})
+
+		Context("call with GID", func() {
+			It("should use existing group as primary group", func() {
+				err := linux.UserCreate(model.NewUser("carol", "100", []string{}, "/bin/bash"))
+				Expect(err).To(BeNil())
+
+				Expect(readFile("etc/passwd")).To(ContainSubstring("carol:x:1001:100::/home/carol:/bin/bash\n"))
+				Expect(readFile("etc/group")).NotTo(ContainSubstring("carol:x:"))
+			})
+		})
+
+		Context("call with unknown group", func() {
+			It("should return error and keep files", func() {
+				err := linux.UserCreate(model.NewUser("carol", "", []string{"missing"}, "/bin/bash"))
+				Expect(err).NotTo(BeNil())
+				Expect(readFile("etc/passwd")).NotTo(ContainSubstring("carol"))
+			})
+		})
+
+		Context("call with existing user", func() {
+			It("should return error", func() {
+				err := linux.UserCreate(model.NewUser("alice", "", []string{}, "/bin/bash"))
+				Expect(err).NotTo(BeNil())
+			})
+		})
+
+		Context("when passwd could not be replaced", func() {
+			AfterEach(func() {
+				renameFile = os.Rename
+			})
+
+			It("should restore group files already replaced and leave no temporary files", func() {
+				group, gshadow := readFile("etc/group"), readFile("etc/gshadow")
+				renameFile = func(from, to string) error {
+					if to == filepath.Join(root, "etc/passwd") {
+						return os.ErrPermission
+					}
+					return os.Rename(from, to)
+				}
+
+				err := linux.UserCreate(model.NewUser("carol", "", []string{"wheel"}, "/bin/bash"))
+				Expect(err).To(Equal(os.ErrPermission))
+
+				Expect(readFile("etc/group")).To(Equal(group))
+				Expect(readFile("etc/gshadow")).To(Equal(gshadow))
+				Expect(readFile("etc/passwd")).NotTo(ContainSubstring("carol"))
+				Expect(readFile("etc/shadow")).NotTo(ContainSubstring("carol"))
+				for _, name := range []string{"passwd", "shadow", "group", "gshadow"} {
+					Expect(filepath.Glob(filepath.Join(root, "etc", "."+name+".*"))).To(BeEmpty())
+				}
+				_, err = os.Stat(filepath.Join(root, "home/carol"))
+				Expect(os.IsNotExist(err)).To(BeTrue())
+			})
+		})
+	})
+
+	Describe("userDelete()", func() {
+		It("should remove user, private group and memberships", func() {
+			err := linux.userDelete(model.NewUser("alice", "", []string{}, "/bin/bash"))
+			Expect(err).To(BeNil())
+
+			Expect(readFile("etc/passwd")).To(Equal("root:x:0:0:root:/root:/bin/bash\n"))
+			Expect(readFile("etc/shadow")).To(Equal("root:*:19000:0:99999:7:::\n"))
+			Expect(readFile("etc/group")).To(Equal("root:x:0:\nwheel:x:10:\nusers:x:100:\nbob:x:1001:\n"))
+			Expect(readFile("etc/gshadow")).To(Equal("root:::\nwheel:::\nusers:::\nbob:!::\n"))
+			Expect(linux.UserExists("alice")).To(BeFalse())
+		})
+	})
+
+	Describe("UserRename()", func() {
+		It("should rename user, personal group, memberships and home", func() {
+			Expect(os.MkdirAll(filepath.Join(root, "home/alice"), 0700)).To(BeNil())
+			Expect(ioutil.WriteFile(filepath.Join(root, "home/alice/.profile"), []byte("# profile\n"), 0644)).To(BeNil())
+
+			err := linux.UserRename("alice", "carol")
+			Expect(err).To(BeNil())
+
+			Expect(readFile("etc/passwd")).To(ContainSubstring("carol:x:1000:1000::/home/carol:/bin/bash\n"))
+			Expect(readFile("etc/shadow")).To(ContainSubstring("carol:*:19000:0:99999:7:::\n"))
+			Expect(readFile("etc/group")).To(Equal("root:x:0:\nwheel:x:10:carol\nusers:x:100:\ncarol:x:1000:\nbob:x:1001:\n"))
+			Expect(readFile("etc/gshadow")).To(Equal("root:::\nwheel:::carol\nusers:::\ncarol:!::\nbob:!::\n"))
+			Expect(readFile("home/carol/.profile")).To(Equal("# profile\n"))
+			Expect(linux.UserExists("alice")).To(BeFalse())
+			Expect(linux.UserExists("carol")).To(BeTrue())
+		})
+
+		It("should return error when new name is taken", func() {
+			Expect(ioutil.WriteFile(filepath.Join(root, "etc/passwd"), []byte(readFile("etc/passwd")+"bob:x:1001:1001::/home/bob:/bin/bash\n"), 0644)).To(BeNil())
+
+			err := linux.UserRename("alice", "bob")
+			Expect(err).NotTo(BeNil())
+			Expect(readFile("etc/passwd")).To(ContainSubstring("alice:x:1000:1000::/home/alice:/bin/bash\n"))
+		})
+	})
+
+	Describe("UserSetGecos()", func() {
+		It("should update comment field of user", func() {
+			err := linux.UserSetGecos("alice", "Alice Doe (alice) <alice@example.com>")
+			Expect(err).To(BeNil())
+
+			Expect(readFile("etc/passwd")).To(ContainSubstring("alice:x:1000:1000:Alice Doe (alice) <alice@example.com>:/home/alice:/bin/bash\n"))
+			Expect(linux.UserGecos("alice")).To(Equal("Alice Doe (alice) <alice@example.com>"))
+		})
+
+		It("should return error for unknown user", func() {
+			Expect(linux.UserSetGecos("carol", "Carol")).NotTo(BeNil())
+		})
+	})
+
+	Describe("lockPasswd()", func() {
+		It("should create lock file and release it", func() {
+			unlock, err := linux.lockPasswd()
+			Expect(err).To(BeNil())
+			unlock()
+
+			_, err = os.Stat(filepath.Join(root, "etc/.pwd.lock"))
+			Expect(err).To(BeNil())
+
+			unlock, err = linux.lockPasswd()
+			Expect(err).To(BeNil())
+			unlock()
+		})
+	})
+})
@@ -0,0 +1,269 @@
+/*
+ * Github Authorized Keys - Use GitHub teams to manage system user accounts and authorized_keys
+ *
+ * Copyright 2016 Cloud Posse, LLC <hello@cloudposse.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	model "github.com/terjekv/github-authorized-keys/model/linux"
+)
+
+const (
+	// ProfileAuto - detect profile from os-release
+	ProfileAuto = model.ProfileAuto
+
+	// ProfileDebian - Debian, Ubuntu and derivatives (adduser)
+	ProfileDebian = model.ProfileDebian
+
+	// ProfileRHEL - RHEL, Fedora, CentOS, Rocky, Alma, Amazon Linux (useradd)
+	ProfileRHEL = model.ProfileRHEL
+
+	// ProfileAlpine - Alpine and other BusyBox based systems
+	ProfileAlpine = model.ProfileAlpine
+
+	// ProfileArch - Arch Linux and derivatives
+	ProfileArch = model.ProfileArch
+
+	// ProfileSUSE - openSUSE and SLES
+	ProfileSUSE = model.ProfileSUSE
+)
+
+// os-release could be in either location, /etc/os-release takes precedence
+var osReleaseFiles = []string{"/etc/os-release", "/usr/lib/os-release"}
+
+// Profile - command templates of distribution
+type Profile struct {
+	Name      string
+	Templates map[string]string
+}
+
+var profiles = map[string]Profile{
+	// We need --force-badname because github users could contains capital letters, what is not acceptable in some distributions
+	// Really regexp to verify badname rely on environment var that set in profile.d so we rarely hit this errors.
+	//
+	// adduser wants user name be the head and flags the tail.
+	ProfileDebian: {Name: ProfileDebian, Templates: map[string]string{
+		"linux_user_add_tpl":          "adduser {username} --disabled-password --force-badname --gecos {gecos} --shell {shell}",
+		"linux_user_add_with_gid_tpl": "adduser {username} --disabled-password --force-badname --gecos {gecos} --shell {shell} --gid {group}",
+		"linux_user_add_with_uid_tpl": "adduser {username} --disabled-password --force-badname --gecos {gecos} --shell {shell} --uid {uid} --gid {gid}",
+		"linux_group_add_tpl":         "addgroup --gid {gid} {group}",
+		"linux_group_del_tpl":         "delgroup {group}",
+		"linux_user_add_to_group_tpl": "adduser {username} {group}",
+		"linux_user_del_tpl":          "deluser {username}",
+		"linux_user_rename_tpl":       "usermod -l {username} -d {home} -m {old_username}",
+		"linux_group_rename_tpl":      "groupmod -n {username} {old_username}",
+		"linux_user_set_gecos_tpl":    "usermod -c {gecos} {username}",
+		"ssh_restart_tpl":             "/usr/sbin/service ssh force-reload",
+	}},
+	ProfileRHEL: {Name: ProfileRHEL, Templates: map[string]string{
+		"linux_user_add_tpl":          "useradd --create-home --comment {gecos} --shell {shell} {username}",
+		"linux_user_add_with_gid_tpl": "useradd --create-home --comment {gecos} --shell {shell} --gid {gid} {username}",
+		"linux_user_add_with_uid_tpl": "useradd --create-home --comment {gecos} --shell {shell} --uid {uid} --gid {gid} {username}",
+		"linux_group_add_tpl":         "groupadd --gid {gid} {group}",
+		"linux_group_del_tpl":         "groupdel {group}",
+		"linux_user_add_to_group_tpl": "usermod -a -G {group} {username}",
+		"linux_user_del_tpl":          "userdel {username}",
+		"linux_user_rename_tpl":       "usermod -l {username} -d {home} -m {old_username}",
+		"linux_group_rename_tpl":      "groupmod -n {username} {old_username}",
+		"linux_user_set_gecos_tpl":    "usermod -c {gecos} {username}",
+		"ssh_restart_tpl":             "systemctl reload sshd.service",
+	}},
+	// BusyBox adduser and addgroup take options before the user name, renames require shadow package
+	ProfileAlpine: {Name: ProfileAlpine, Templates: map[string]string{
+		"linux_user_add_tpl":          "adduser -D -g {gecos} -s {shell} {username}",
+		"linux_user_add_with_gid_tpl": "adduser -D -g {gecos} -s {shell} -G {group} {username}",
+		"linux_user_add_with_uid_tpl": "adduser -D -g {gecos} -s {shell} -u {uid} -G {group} {username}",
+		"linux_group_add_tpl":         "addgroup -g {gid} {group}",
+		"linux_group_del_tpl":         "delgroup {group}",
+		"linux_user_add_to_group_tpl": "addgroup {username} {group}",
+		"linux_user_del_tpl":          "deluser {username}",
+		"linux_user_rename_tpl":       "usermod -l {username} -d {home} -m {old_username}",
+		"linux_group_rename_tpl":      "groupmod -n {username} {old_username}",
+		"linux_user_set_gecos_tpl":    "usermod -c {gecos} {username}",
+		"ssh_restart_tpl":             "rc-service sshd restart",
+	}},
+	ProfileArch: {Name: ProfileArch, Templates: map[string]string{
+		"linux_user_add_tpl":          "useradd --create-home --comment {gecos} --shell {shell} {username}",
+		"linux_user_add_with_gid_tpl": "useradd --create-home --comment {gecos} --shell {shell} --gid {gid} {username}",
+		"linux_user_add_with_uid_tpl": "useradd --create-home --comment {gecos} --shell {shell} --uid {uid} --gid {gid} {username}",
+		"linux_group_add_tpl":         "groupadd --gid {gid} {group}",
+		"linux_group_del_tpl":         "groupdel {group}",
+		"linux_user_add_to_group_tpl": "gpasswd -a {username} {group}",
+		"linux_user_del_tpl":          "userdel {username}",
+		"linux_user_rename_tpl":       "usermod -l {username} -d {home} -m {old_username}",
+		"linux_group_rename_tpl":      "groupmod -n {username} {old_username}",
+		"linux_user_set_gecos_tpl":    "usermod -c {gecos} {username}",
+		"ssh_restart_tpl":             "systemctl reload sshd.service",
+	}},
+	ProfileSUSE: {Name: ProfileSUSE, Templates: map[string]string{
+		"linux_user_add_tpl":          "useradd --create-home --comment {gecos} --shell {shell} {username}",
+		"linux_user_add_with_gid_tpl": "useradd --create-home --comment {gecos} --shell {shell} --gid {gid} {username}",
+		"linux_user_add_with_uid_tpl": "useradd --create-home --comment {gecos} --shell {shell} --uid {uid} --gid {gid} {username}",
+		"linux_group_add_tpl":         "groupadd --gid {gid} {group}",
+		"linux_group_del_tpl":         "groupdel {group}",
+		"linux_user_add_to_group_tpl": "usermod -a -G {group} {username}",
+		"linux_user_del_tpl":          "userdel {username}",
+		"linux_user_rename_tpl":       "usermod -l {username} -d {home} -m {old_username}",
+		"linux_group_rename_tpl":      "groupmod -n {username} {old_username}",
+		"linux_user_set_gecos_tpl":    "usermod -c {gecos} {username}",
+		"ssh_restart_tpl":             "systemctl reload sshd.service",
+	}},
+}
+
+// Distribution IDs and ID_LIKE values of os-release mapped to profiles
+// detectedProfiles - names of profiles detected from os-release, by root, so os-release is read once
+var (
+	detectedProfilesMutex sync.Mutex
+	detectedProfiles      = map[string]string{}
+)
+
+var distributions = map[string]string{
+	"debian":    ProfileDebian,
+	"ubuntu":    ProfileDebian,
+	"raspbian":  ProfileDebian,
+	"rhel":      ProfileRHEL,
+	"fedora":    ProfileRHEL,
+	"centos":    ProfileRHEL,
+	"rocky":     ProfileRHEL,
+	"almalinux": ProfileRHEL,
+	"ol":        ProfileRHEL,
+	"amzn":      ProfileRHEL,
+	"alpine":    ProfileAlpine,
+	"arch":      ProfileArch,
+	"manjaro":   ProfileArch,
+	"suse":      ProfileSUSE,
+	"opensuse":  ProfileSUSE,
+	"sles":      ProfileSUSE,
+}
+
+// OSRelease - return ID and ID_LIKE values of os-release under root
+func (linux *Linux) OSRelease() (id string, idLike []string) {
+	for _, file := range osReleaseFiles {
+		content, err := linux.FileGet(file)
+		if err != nil {
+			continue
+		}
+
+		for _, line := range strings.Split(content, "\n") {
+			key, value, found := strings.Cut(strings.TrimSpace(line), "=")
+			if !found {
+				continue
+			}
+			value = strings.Trim(value, "\"'")
+
+			switch key {
+			case "ID":
+				id = strings.ToLower(value)
+			case "ID_LIKE":
+				idLike = strings.Fields(strings.ToLower(value))
+			}
+		}
+		return
+	}
+	return
+}
+
+// TemplateNames - return option names of command templates
+func TemplateNames() []string {
+	names := []string{}
+	for name := range profiles[ProfileDebian].Templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Profile - return profile selected by settings or detected from os-release, debian if detection failed.
+// Detected profile is cached, os-release is read on the first call only.
+func (linux *Linux) Profile() Profile {
+	logger := log.WithFields(log.Fields{"class": "Linux", "method": "Profile"})
+
+	if name := linux.profile; name != "" && name != ProfileAuto {
+		if profile, ok := profiles[name]; ok {
+			return profile
+		}
+		logger.Warnf("Unknown linux profile %v, detect it from os-release", name)
+	}
+
+	detectedProfilesMutex.Lock()
+	defer detectedProfilesMutex.Unlock()
+
+	if name, ok := detectedProfiles[linux.root]; ok {
+		return profiles[name]
+	}
+
+	name := linux.detectProfile()
+	detectedProfiles[linux.root] = name
+	return profiles[name]
+}
+
+// detectProfile - return name of profile of distribution from os-release, debian if detection failed
+func (linux *Linux) detectProfile() string {
+	logger := log.WithFields(log.Fields{"class": "Linux", "method": "detectProfile"})
+
+	id, idLike := linux.OSRelease()
+	for _, candidate := range append([]string{id}, idLike...) {
+		// openSUSE uses IDs like opensuse-leap and opensuse-tumbleweed
+		candidate = strings.SplitN(candidate, "-", 2)[0]
+		if name, ok := distributions[candidate]; ok {
+			logger.Debugf("Use linux profile %v of distribution %v", name, id)
+			return name
+		}
+	}
+
+	logger.Debugf("Unable to detect linux profile of distribution %v, use %v", id, ProfileDebian)
+	return ProfileDebian
+}
+
+// Template - return command template {key} as list of arguments.
+// Template set in settings is used, otherwise template of profile.
+func (linux *Linux) Template(key string) []string {
+	if template, ok := linux.templates[key]; ok {
+		return template
+	}
+	return strings.Fields(linux.Profile().Templates[key])
+}
+
+// ResolveTemplates - return all command templates: ones set in {options} by option name, others of profile.
+// Templates given as string are split on whitespace before placeholders are substituted,
+// templates given as list are used as arguments as is.
+func (linux *Linux) ResolveTemplates(options map[string]interface{}) map[string][]string {
+	templates := map[string][]string{}
+	for _, key := range TemplateNames() {
+		switch value := options[key].(type) {
+		case nil:
+			templates[key] = strings.Fields(linux.Profile().Templates[key])
+		case []string:
+			templates[key] = value
+		case []interface{}:
+			template := make([]string, 0, len(value))
+			for _, argument := range value {
+				template = append(template, fmt.Sprint(argument))
+			}
+			templates[key] = template
+		default:
+			templates[key] = strings.Fields(fmt.Sprint(value))
+		}
+	}
+	return templates
+}
@@ -0,0 +1,127 @@
+/*
+ * Github Authorized Keys - Use GitHub teams to manage system user accounts and authorized_keys
+ *
+ * Copyright 2016 Cloud Posse, LLC <hello@cloudposse.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package api
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/terjekv/github-authorized-keys/config"
+)
+
+var _ = Describe("Linux profiles", func() {
+	var (
+		root  string
+		linux Linux
+	)
+
+	writeOSRelease := func(content string) {
+		Expect(ioutil.WriteFile(filepath.Join(root, "etc/os-release"), []byte(content), 0644)).To(BeNil())
+	}
+
+	BeforeEach(func() {
+		var err error
+		root, err = ioutil.TempDir("", "gak-profiles")
+		Expect(err).To(BeNil())
+		Expect(os.MkdirAll(filepath.Join(root, "etc"), 0755)).To(BeNil())
+		linux = NewLinux(root)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(root)
+	})
+
+	DescribeTable("Profile() detects distribution from os-release",
+		func(osRelease, expected string) {
+			writeOSRelease(osRelease)
+			Expect(linux.Profile().Name).To(Equal(expected))
+		},
+		Entry("Debian", "ID=debian\n", ProfileDebian),
+		Entry("Ubuntu", "NAME=\"Ubuntu\"\nID=ubuntu\nID_LIKE=debian\n", ProfileDebian),
+		Entry("Rocky", "ID=\"rocky\"\nID_LIKE=\"rhel centos fedora\"\n", ProfileRHEL),
+		Entry("Fedora", "ID=fedora\n", ProfileRHEL),
+		Entry("Alpine", "ID=alpine\n", ProfileAlpine),
+		Entry("Manjaro", "ID=manjaro\nID_LIKE=arch\n", ProfileArch),
+		Entry("openSUSE", "ID=\"opensuse-leap\"\nID_LIKE=\"suse opensuse\"\n", ProfileSUSE),
+		Entry("derivative by ID_LIKE", "ID=pop\nID_LIKE=\"ubuntu debian\"\n", ProfileDebian),
+		Entry("unknown", "ID=plan9\n", ProfileDebian),
+	)
+
+	Context("without os-release", func() {
+		It("should fall back to debian", func() {
+			Expect(linux.Profile().Name).To(Equal(ProfileDebian))
+		})
+	})
+
+	Context("with os-release changed after detection", func() {
+		It("should keep detected profile", func() {
+			writeOSRelease("ID=alpine\n")
+			Expect(linux.Profile().Name).To(Equal(ProfileAlpine))
+			writeOSRelease("ID=fedora\n")
+			Expect(linux.Profile().Name).To(Equal(ProfileAlpine))
+		})
+	})
+
+	Context("with profile option", func() {
+		It("should use profile without detection", func() {
+			writeOSRelease("ID=debian\n")
+			linux = NewConfiguredLinux(config.Config{Root: root, LinuxProfile: ProfileAlpine})
+			Expect(linux.Profile().Name).To(Equal(ProfileAlpine))
+		})
+	})
+
+	Describe("Template()", func() {
+		It("should return template of profile", func() {
+			writeOSRelease("ID=alpine\n")
+			Expect(linux.Template("linux_user_add_tpl")).To(Equal([]string{"adduser", "-D", "-g", "{gecos}", "-s", "{shell}", "{username}"}))
+		})
+
+		It("should return template of settings", func() {
+			writeOSRelease("ID=alpine\n")
+			linux = NewConfiguredLinux(config.Config{Root: root, Templates: map[string][]string{"linux_user_add_tpl": {"useradd", "{username}"}}})
+			Expect(linux.Template("linux_user_add_tpl")).To(Equal([]string{"useradd", "{username}"}))
+			Expect(linux.Template("linux_user_del_tpl")).To(Equal([]string{"deluser", "{username}"}))
+		})
+	})
+
+	Describe("ResolveTemplates()", func() {
+		It("should resolve every template from options and profile", func() {
+			writeOSRelease("ID=alpine\n")
+			templates := linux.ResolveTemplates(map[string]interface{}{
+				"linux_user_add_tpl": "useradd {username}",
+				"linux_user_del_tpl": []interface{}{"userdel", "--comment", "GitHub user {username}", "{username}"},
+			})
+
+			Expect(templates).To(HaveLen(len(TemplateNames())))
+			Expect(templates["linux_user_add_tpl"]).To(Equal([]string{"useradd", "{username}"}))
+			Expect(templates["linux_user_del_tpl"]).To(Equal([]string{"userdel", "--comment", "GitHub user {username}", "{username}"}))
+			Expect(templates["linux_group_del_tpl"]).To(Equal([]string{"delgroup", "{group}"}))
+		})
+
+		It("should use templates of profile set in settings", func() {
+			writeOSRelease("ID=alpine\n")
+			linux = NewConfiguredLinux(config.Config{Root: root, LinuxProfile: ProfileRHEL})
+			Expect(linux.ResolveTemplates(nil)["linux_user_del_tpl"]).To(Equal([]string{"userdel", "{username}"}))
+		})
+	})
+})
@@ -303,4 +303,67 @@ var _ = Describe("Linux", func() {
 		})
 	})
 
+	Describe("UserStatus()", func() {
+		Context("call with existing user", func() {
+			It("should return present", func() {
+				linux := NewLinux("/")
+				Expect(linux.UserStatus("root")).To(Equal(UserStatusPresent))
+			})
+		})
+
+		Context("call with system user without login shell", func() {
+			It("should return locked", func() {
+				linux := NewLinux("/")
+				Expect(linux.UserStatus("nobody")).To(Equal(UserStatusLocked))
+			})
+		})
+
+		Context("call with not existing user", func() {
+			It("should return missing", func() {
+				linux := NewLinux("/")
+				Expect(linux.UserStatus("testdsadasfsa")).To(Equal(UserStatusMissing))
+			})
+		})
+	})
+
+	Describe("TemplateCommand()", func() {
+		linux := NewLinux("/")
+
+		Context("call with value containing spaces", func() {
+			It("should expand it to a single argument", func() {
+				cmd, err := linux.TemplateCommand([]string{"useradd", "--shell", "{shell}", "--comment", "User {username}", "{username}"},
+					map[string]interface{}{"shell": "/opt/my shell", "username": "test"})
+
+				Expect(err).To(BeNil())
+				Expect(cmd.Args).To(Equal([]string{"useradd", "--shell", "/opt/my shell", "--comment", "User test", "test"}))
+			})
+		})
+
+		Context("call with value starting with dash", func() {
+			It("should return error", func() {
+				cmd, err := linux.TemplateCommand([]string{"deluser", "{username}"},
+					map[string]interface{}{"username": "--remove-all-files"})
+
+				Expect(err).NotTo(BeNil())
+				Expect(cmd).To(BeNil())
+			})
+		})
+
+		Context("call with placeholder without value", func() {
+			It("should return error", func() {
+				_, err := linux.TemplateCommand([]string{"adduser", "{username}", "{group}"},
+					map[string]interface{}{"username": "test"})
+
+				Expect(err).NotTo(BeNil())
+			})
+		})
+
+		Context("call with empty template", func() {
+			It("should return error", func() {
+				_, err := linux.TemplateCommand([]string{}, map[string]interface{}{})
+
+				Expect(err).To(Equal(ErrorEmptyTemplate))
+			})
+		})
+	})
 })
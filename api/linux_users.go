@@ -21,10 +21,12 @@ package api
 import (
 	"errors"
 	"fmt"
-	"os/exec"
 	"os/user"
+	"path"
+	"strconv"
+	"time"
 
-	"github.com/spf13/viper"
+	"github.com/terjekv/github-authorized-keys/metrics"
 	"github.com/terjekv/github-authorized-keys/model/linux"
 )
 
@@ -56,16 +58,20 @@ const (
 	shellColumnNumberInPasswd = 6
 )
 
-func init() {
-	// We need --force-badname because github users could contains capital letters, what is not acceptable in some distributions
-	// Really regexp to verify badname rely on environment var that set in profile.d so we rarely hit this errors.
-	//
-	// adduser wants user name be the head and flags the tail.
-	viper.SetDefault("linux_user_add_tpl", "adduser {username} --disabled-password --force-badname --shell {shell}")
-	viper.SetDefault("linux_user_add_with_gid_tpl", "adduser {username} --disabled-password --force-badname --shell {shell} --gid {group}")
-	viper.SetDefault("linux_user_add_to_group_tpl", "adduser {username} {group}")
-	viper.SetDefault("linux_user_del_tpl", "deluser {username}")
-}
+const (
+	// UserStatusPresent - linux account exists and could log in
+	UserStatusPresent = "present"
+
+	// UserStatusMissing - linux account does not exist
+	UserStatusMissing = "missing"
+
+	// UserStatusLocked - linux account exists, but is expired or has no login shell
+	UserStatusLocked = "locked"
+)
+
+// Shadow file contains expiration date in 7 column as days since Jan 1, 1970
+// https://en.wikipedia.org/wiki/Passwd#Shadow_file
+const expireColumnNumberInShadow = 7
 
 // UserExists - check if user {userName} exists
 func (linux *Linux) UserExists(userName string) bool {
@@ -97,21 +103,34 @@ func (linux *Linux) userLookup(userName string) (*user.User, error) {
 
 // UserCreate - create user {new}
 func (linux *Linux) UserCreate(new linux.User) error {
+	if linux.isNative() {
+		return linux.nativeUserCreate(new)
+	}
 
-	createUserCommandTemplate := viper.GetString("linux_user_add_tpl")
-	createUserWithGIDCommandTemplate := viper.GetString("linux_user_add_with_gid_tpl")
-	addUserToGroupCommandTemplate := viper.GetString("linux_user_add_to_group_tpl")
-
-	var cmd *exec.Cmd
+	createUserCommandTemplate := linux.Template("linux_user_add_tpl")
+	createUserWithGIDCommandTemplate := linux.Template("linux_user_add_with_gid_tpl")
+	createUserWithUIDCommandTemplate := linux.Template("linux_user_add_with_uid_tpl")
+	addUserToGroupCommandTemplate := linux.Template("linux_user_add_to_group_tpl")
 
 	template := createUserCommandTemplate
 
 	args := map[string]interface{}{
 		"shell":    new.Shell(),
 		"username": new.Name(),
+		"gecos":    new.Gecos(),
 	}
 
-	if new.Gid() != "" {
+	if new.Uid() != "" {
+		// Personal group gets the same id as the user
+		args["uid"] = new.Uid()
+		args["gid"] = new.Uid()
+		args["group"] = new.Name()
+		template = createUserWithUIDCommandTemplate
+
+		if err := linux.groupCreate(new.Name(), new.Uid()); err != nil {
+			return err
+		}
+	} else if new.Gid() != "" {
 		args["gid"] = new.Gid()
 		template = createUserWithGIDCommandTemplate
 
@@ -120,20 +139,40 @@ func (linux *Linux) UserCreate(new linux.User) error {
 		}
 	}
 
-	cmd = linux.TemplateCommand(template, args)
+	cmd, err := linux.TemplateCommand(template, args)
+	if err != nil {
+		return err
+	}
 	// cmd.Run called inside CombinedOutput()
 	out, err := cmd.CombinedOutput()
 	if err != nil {
 		fmt.Printf("%v\n", string(out))
+		// Personal group left behind would take the derived id and move the user to another id on retry
+		if new.Uid() != "" {
+			if groupErr := linux.groupDelete(new.Name()); groupErr != nil {
+				fmt.Printf("Unable to delete group %v: %v\n", new.Name(), groupErr)
+			}
+		}
 		return err
 	}
 
 	fmt.Printf("Created user %v\n", new.Name())
+	metrics.UsersCreated.Inc()
+
+	// Templates without {gecos} placeholder leave comment empty. User exists already, so groups are added anyway.
+	if new.Gecos() != "" && linux.UserGecos(new.Name()) != new.Gecos() {
+		if err := linux.UserSetGecos(new.Name(), new.Gecos()); err != nil {
+			fmt.Printf("Unable to set GECOS of user %v: %v\n", new.Name(), err)
+		}
+	}
 
 	for _, group := range new.Groups() {
-		cmd := linux.TemplateCommand(addUserToGroupCommandTemplate,
+		cmd, err := linux.TemplateCommand(addUserToGroupCommandTemplate,
 			map[string]interface{}{"username": new.Name(), "group": group})
-		err := cmd.Run()
+		if err != nil {
+			return err
+		}
+		err = cmd.Run()
 		if err != nil {
 			return err
 		}
@@ -144,11 +183,98 @@ func (linux *Linux) UserCreate(new linux.User) error {
 }
 
 func (linux *Linux) userDelete(new linux.User) error {
-	deleteUserCommandTemplate := viper.GetString("linux_user_del_tpl")
+	deleteUserCommandTemplate := linux.Template("linux_user_del_tpl")
 
 	fmt.Printf("Delete user %v\n", new.Name())
-	cmd := linux.TemplateCommand(deleteUserCommandTemplate, map[string]interface{}{"username": new.Name()})
-	return cmd.Run()
+	if linux.isNative() {
+		if err := linux.nativeUserDelete(new.Name()); err != nil {
+			return err
+		}
+		metrics.UsersRemoved.Inc()
+		return nil
+	}
+
+	cmd, err := linux.TemplateCommand(deleteUserCommandTemplate, map[string]interface{}{"username": new.Name()})
+	if err != nil {
+		return err
+	}
+	if err := cmd.Run(); err != nil {
+		return err
+	}
+	metrics.UsersRemoved.Inc()
+	return nil
+}
+
+// UserRename - rename user {oldName} to {newName}, move its home directory and rename its personal group
+func (linux *Linux) UserRename(oldName, newName string) error {
+	if linux.isNative() {
+		return linux.nativeUserRename(oldName, newName)
+	}
+
+	oldUser, err := linux.userLookup(oldName)
+	if err != nil {
+		return err
+	}
+
+	args := map[string]interface{}{
+		"username":     newName,
+		"old_username": oldName,
+		"home":         renamedHome(oldUser.HomeDir, oldName, newName),
+	}
+
+	cmd, err := linux.TemplateCommand(linux.Template("linux_user_rename_tpl"), args)
+	if err != nil {
+		return err
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		fmt.Printf("%v\n", string(out))
+		return err
+	}
+
+	if group, err := linux.groupLookup(oldName); err == nil && group.Gid == oldUser.Gid {
+		cmd, err := linux.TemplateCommand(linux.Template("linux_group_rename_tpl"), args)
+		if err != nil {
+			return err
+		}
+		if out, err := cmd.CombinedOutput(); err != nil {
+			fmt.Printf("%v\n", string(out))
+			return err
+		}
+	}
+
+	fmt.Printf("Renamed user %v to %v\n", oldName, newName)
+	return nil
+}
+
+// renamedHome - return home directory of renamed user, home is moved only when it is named after the user
+func renamedHome(home, oldName, newName string) string {
+	if path.Base(home) != oldName {
+		return home
+	}
+	return path.Join(path.Dir(home), newName)
+}
+
+// UserStatus - return status of linux account {userName}: present, missing or locked
+func (linux *Linux) UserStatus(userName string) string {
+	if !linux.UserExists(userName) {
+		return UserStatusMissing
+	}
+
+	switch path.Base(linux.userShell(userName)) {
+	case "nologin", "false":
+		return UserStatusLocked
+	}
+
+	// Shadow database is readable only by root, so expiration is checked when available
+	shadowInfo, err := linux.getEntity("shadow", userName)
+	if err == nil && len(shadowInfo) > expireColumnNumberInShadow && shadowInfo[expireColumnNumberInShadow] != "" {
+		expireDays, err := strconv.ParseInt(shadowInfo[expireColumnNumberInShadow], 10, 64)
+		if err == nil && time.Now().Unix()/(24*60*60) >= expireDays {
+			return UserStatusLocked
+		}
+	}
+
+	return UserStatusPresent
 }
 
 func (linux *Linux) userShell(userName string) string {
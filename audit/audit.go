@@ -0,0 +1,191 @@
+/*
+ * Github Authorized Keys - Use GitHub teams to manage system user accounts and authorized_keys
+ *
+ * Copyright 2016 Cloud Posse, LLC <hello@cloudposse.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/syslog"
+	"os"
+	"sync"
+	"time"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Supported audit outputs
+const (
+	// OutputNone - audit is disabled
+	OutputNone = "none"
+	// OutputStdout - JSON lines written to stdout
+	OutputStdout = "stdout"
+	// OutputSyslog - JSON lines sent to local syslog with auth facility
+	OutputSyslog = "syslog"
+	// OutputFile - JSON lines appended to file rotated by size
+	OutputFile = "file"
+)
+
+// Decisions of key lookups
+const (
+	// DecisionAllow - keys were returned
+	DecisionAllow = "allow"
+	// DecisionDeny - no keys were returned
+	DecisionDeny = "deny"
+)
+
+// Reasons of decisions
+const (
+	// ReasonMember - user is a team member, keys fetched from GitHub
+	ReasonMember = "member"
+	// ReasonCache - GitHub is unavailable, keys served from fallback cache
+	ReasonCache = "served_from_cache"
+	// ReasonNotInTeam - user is not a member of configured teams
+	ReasonNotInTeam = "not_in_team"
+	// ReasonNotFound - user is not found on GitHub
+	ReasonNotFound = "not_found"
+	// ReasonNoKeys - user has no keys or none matched requested fingerprint
+	ReasonNoKeys = "no_keys"
+	// ReasonStaleCache - GitHub is unavailable and cached keys are too old
+	ReasonStaleCache = "stale_cache"
+	// ReasonGithubError - GitHub is unavailable and there is no cached keys
+	ReasonGithubError = "github_error"
+	// ReasonInvalidUser - requested name could not be GitHub login
+	ReasonInvalidUser = "invalid_username"
+	// ReasonRateLimited - client exceeded its request rate
+	ReasonRateLimited = "rate_limited"
+)
+
+// Account events
+const (
+	// EventAccountRenamed - linux account was renamed after its GitHub login changed
+	EventAccountRenamed = "account_renamed"
+	// EventAccountAliased - linux account kept its name after its GitHub login changed
+	EventAccountAliased = "account_aliased"
+)
+
+// Options - audit output settings
+type Options struct {
+	Output string
+
+	// File output settings
+	File       string
+	MaxSize    int // megabytes
+	MaxBackups int
+	MaxAge     int // days
+}
+
+// Record - single key lookup or account event
+type Record struct {
+	Time         time.Time `json:"time"`
+	Event        string    `json:"event,omitempty"`
+	User         string    `json:"user"`
+	PreviousUser string    `json:"previous_user,omitempty"`
+	GithubID     int64     `json:"github_id,omitempty"`
+	Decision     string    `json:"decision,omitempty"`
+	Reason       string    `json:"reason,omitempty"`
+	Source       string    `json:"source,omitempty"`
+	Fingerprints []string  `json:"fingerprints"`
+	Client       string    `json:"client,omitempty"`
+}
+
+var (
+	mutex  sync.Mutex
+	writer io.Writer
+	closer io.Closer
+)
+
+// Setup - open audit output, records are dropped until it is called
+func Setup(options Options) error {
+	var (
+		output io.Writer
+		c      io.Closer
+	)
+
+	switch options.Output {
+	case "", OutputNone:
+	case OutputStdout:
+		output = os.Stdout
+	case OutputSyslog:
+		logger, err := syslog.New(syslog.LOG_AUTH|syslog.LOG_INFO, "github-authorized-keys")
+		if err != nil {
+			return err
+		}
+		output, c = logger, logger
+	case OutputFile:
+		if options.File == "" {
+			return fmt.Errorf("audit file is required for %v output", OutputFile)
+		}
+		logger := &lumberjack.Logger{
+			Filename:   options.File,
+			MaxSize:    options.MaxSize,
+			MaxBackups: options.MaxBackups,
+			MaxAge:     options.MaxAge,
+		}
+		output, c = logger, logger
+	default:
+		return fmt.Errorf("unknown audit output %v", options.Output)
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if closer != nil {
+		closer.Close()
+	}
+	writer, closer = output, c
+	return nil
+}
+
+// Log - write record as single JSON line
+func Log(record Record) error {
+	if record.Time.IsZero() {
+		record.Time = time.Now().UTC()
+	}
+	if record.Fingerprints == nil {
+		record.Fingerprints = []string{}
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if writer == nil {
+		return nil
+	}
+	_, err = writer.Write(append(line, '\n'))
+	return err
+}
+
+// Close - flush and close audit output
+func Close() error {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	var err error
+	if closer != nil {
+		err = closer.Close()
+	}
+	writer, closer = nil, nil
+	return err
+}
@@ -0,0 +1,151 @@
+/*
+ * Github Authorized Keys - Use GitHub teams to manage system user accounts and authorized_keys
+ *
+ * Copyright 2016 Cloud Posse, LLC <hello@cloudposse.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Audit", func() {
+	// decode - parse JSON lines of {content} into events
+	decode := func(content string) []map[string]interface{} {
+		events := []map[string]interface{}{}
+		scanner := bufio.NewScanner(strings.NewReader(content))
+		for scanner.Scan() {
+			event := map[string]interface{}{}
+			Expect(json.Unmarshal(scanner.Bytes(), &event)).To(BeNil())
+			events = append(events, event)
+		}
+		return events
+	}
+
+	lookup := Record{User: "goruha", GithubID: 42, Decision: DecisionAllow, Reason: ReasonMember, Source: "github",
+		Fingerprints: []string{"SHA256:abc"}, Client: "10.0.0.7"}
+	rename := Record{Event: EventAccountRenamed, User: "jdoe", PreviousUser: "john"}
+
+	AfterEach(func() {
+		Expect(Close()).To(BeNil())
+	})
+
+	Describe("file output", func() {
+		var dir string
+
+		BeforeEach(func() {
+			var err error
+			dir, err = ioutil.TempDir("", "gak-audit")
+			Expect(err).To(BeNil())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("should write one JSON event per line", func() {
+			file := filepath.Join(dir, "audit.log")
+			Expect(Setup(Options{Output: OutputFile, File: file, MaxSize: 1})).To(BeNil())
+			Expect(Log(lookup)).To(BeNil())
+			Expect(Log(rename)).To(BeNil())
+			Expect(Close()).To(BeNil())
+
+			content, err := ioutil.ReadFile(file)
+			Expect(err).To(BeNil())
+			Expect(strings.Count(string(content), "\n")).To(Equal(2))
+
+			events := decode(string(content))
+			Expect(events).To(HaveLen(2))
+			Expect(events[0]).To(HaveKey("time"))
+			Expect(events[0]).To(HaveKeyWithValue("user", "goruha"))
+			Expect(events[0]).To(HaveKeyWithValue("github_id", float64(42)))
+			Expect(events[0]).To(HaveKeyWithValue("decision", DecisionAllow))
+			Expect(events[0]).To(HaveKeyWithValue("reason", ReasonMember))
+			Expect(events[0]).To(HaveKeyWithValue("source", "github"))
+			Expect(events[0]).To(HaveKeyWithValue("fingerprints", []interface{}{"SHA256:abc"}))
+			Expect(events[0]).To(HaveKeyWithValue("client", "10.0.0.7"))
+			Expect(events[0]).NotTo(HaveKey("event"))
+
+			Expect(events[1]).To(HaveKeyWithValue("event", EventAccountRenamed))
+			Expect(events[1]).To(HaveKeyWithValue("user", "jdoe"))
+			Expect(events[1]).To(HaveKeyWithValue("previous_user", "john"))
+			Expect(events[1]).To(HaveKeyWithValue("fingerprints", []interface{}{}))
+			Expect(events[1]).NotTo(HaveKey("decision"))
+		})
+
+		It("should require file name", func() {
+			Expect(Setup(Options{Output: OutputFile})).NotTo(BeNil())
+		})
+	})
+
+	Describe("stdout output", func() {
+		It("should write one JSON event per line", func() {
+			reader, pipe, err := os.Pipe()
+			Expect(err).To(BeNil())
+			stdout := os.Stdout
+			os.Stdout = pipe
+			err = Setup(Options{Output: OutputStdout})
+			os.Stdout = stdout
+			Expect(err).To(BeNil())
+
+			Expect(Log(lookup)).To(BeNil())
+			Expect(Log(rename)).To(BeNil())
+			Expect(Close()).To(BeNil())
+			pipe.Close()
+
+			content, err := ioutil.ReadAll(reader)
+			Expect(err).To(BeNil())
+			events := decode(string(content))
+			Expect(events).To(HaveLen(2))
+			Expect(events[0]).To(HaveKeyWithValue("user", "goruha"))
+			Expect(events[0]).To(HaveKeyWithValue("decision", DecisionAllow))
+			Expect(events[1]).To(HaveKeyWithValue("event", EventAccountRenamed))
+		})
+	})
+
+	Describe("none output", func() {
+		It("should drop records", func() {
+			dir, err := ioutil.TempDir("", "gak-audit")
+			Expect(err).To(BeNil())
+			defer os.RemoveAll(dir)
+
+			// Output of previous setup is closed and no longer written
+			file := filepath.Join(dir, "audit.log")
+			Expect(Setup(Options{Output: OutputFile, File: file})).To(BeNil())
+			Expect(Setup(Options{Output: OutputNone})).To(BeNil())
+			Expect(Log(lookup)).To(BeNil())
+
+			content, _ := ioutil.ReadFile(file)
+			Expect(content).To(BeEmpty())
+		})
+
+		It("should drop records before setup", func() {
+			Expect(Log(lookup)).To(BeNil())
+		})
+	})
+
+	It("should reject unknown output", func() {
+		Expect(Setup(Options{Output: "kafka"})).To(MatchError(ContainSubstring("unknown audit output kafka")))
+	})
+})
@@ -0,0 +1,165 @@
+/*
+ * Github Authorized Keys - Use GitHub teams to manage system user accounts and authorized_keys
+ *
+ * Copyright 2016 Cloud Posse, LLC <hello@cloudposse.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"github.com/terjekv/github-authorized-keys/api"
+	"github.com/terjekv/github-authorized-keys/config"
+)
+
+// Options that are not command line flags, but could be set in flat config file
+var fileOnlyOptions = []string{
+	"log_level",
+	"github_api_max_page_size",
+	"ssh_restart_tpl",
+	"authorized_keys_command_tpl",
+	"linux_user_add_tpl",
+	"linux_user_add_with_gid_tpl",
+	"linux_user_add_with_uid_tpl",
+	"linux_user_add_to_group_tpl",
+	"linux_group_add_tpl",
+	"linux_group_del_tpl",
+	"linux_user_del_tpl",
+	"linux_user_rename_tpl",
+	"linux_group_rename_tpl",
+	"linux_user_set_gecos_tpl",
+}
+
+// validateSystem - check that {cfg} matches the host, groups are looked up with configured users backend
+func validateSystem(cfg config.Config) error {
+	linux := api.NewConfiguredLinux(cfg)
+	return cfg.ValidateSystem(linux.GroupExists)
+}
+
+// readConfig - read config file if any. Files with version are decoded strictly by nested schema,
+// unknown keys of flat files are reported as warnings.
+func readConfig() error {
+	if err := viper.ReadInConfig(); err != nil {
+		// Default config file is optional
+		if errors.As(err, &viper.ConfigFileNotFoundError{}) {
+			return nil
+		}
+		return err
+	}
+
+	if !viper.InConfig("version") {
+		for _, key := range unknownOptions(viper.ConfigFileUsed()) {
+			log.WithFields(log.Fields{"class": "RootCmd", "method": "readConfig"}).
+				Warnf("Unknown option %v in config file %v is ignored", key, viper.ConfigFileUsed())
+		}
+		return nil
+	}
+
+	content, err := ioutil.ReadFile(viper.ConfigFileUsed())
+	if err != nil {
+		return err
+	}
+
+	options, err := config.ParseFile(content)
+	if err != nil {
+		return err
+	}
+
+	return viper.MergeConfigMap(options)
+}
+
+// unknownOptions - return keys of flat config file {path} that are not known options
+func unknownOptions(path string) []string {
+	known := map[string]bool{}
+	for _, f := range append(append([]flag{}, flags...), lookupFlags...) {
+		known[f.option] = true
+	}
+	for _, option := range fileOnlyOptions {
+		known[option] = true
+	}
+
+	file := viper.New()
+	file.SetConfigFile(path)
+	if err := file.ReadInConfig(); err != nil {
+		return []string{}
+	}
+
+	unknown := []string{}
+	for key := range file.AllSettings() {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect configuration",
+}
+
+// configValidateCmd - check config file, environment variables and host without starting the server
+var configValidateCmd = &cobra.Command{
+	Use:           "validate",
+	Short:         "Validate configuration and check it matches the host",
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if configErr != nil {
+			return configErr
+		}
+
+		// Unknown keys of nested files are already rejected by readConfig
+		if !viper.InConfig("version") {
+			if unknown := unknownOptions(viper.ConfigFileUsed()); len(unknown) > 0 {
+				return fmt.Errorf("unknown options in config file: %v", unknown)
+			}
+		}
+
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		if err := cfg.Validate(); err != nil {
+			return err
+		}
+
+		if err := validateSystem(cfg); err != nil {
+			return err
+		}
+
+		if viper.ConfigFileUsed() != "" {
+			fmt.Printf("Config file %v is valid\n", viper.ConfigFileUsed())
+		} else {
+			fmt.Println("Config is valid")
+		}
+		return nil
+	},
+}
+
+func init() {
+	configCmd.AddCommand(configValidateCmd)
+	RootCmd.AddCommand(configCmd)
+}
@@ -0,0 +1,87 @@
+/*
+ * Github Authorized Keys - Use GitHub teams to manage system user accounts and authorized_keys
+ *
+ * Copyright 2016 Cloud Posse, LLC <hello@cloudposse.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/spf13/viper"
+)
+
+var _ = Describe("Config file", func() {
+	var dir string
+
+	writeConfig := func(content string) string {
+		path := filepath.Join(dir, "config.yaml")
+		Expect(ioutil.WriteFile(path, []byte(content), 0600)).To(BeNil())
+		return path
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "gak-config")
+		Expect(err).To(BeNil())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	Describe("unknownOptions()", func() {
+		It("should return keys that are neither flags nor file only options", func() {
+			path := writeConfig("github_organization: cloudposse\nlinux_user_add_tpl: useradd {username}\nbogus_option: true\ngithub_orgnization: typo\n")
+			Expect(unknownOptions(path)).To(Equal([]string{"bogus_option", "github_orgnization"}))
+		})
+
+		It("should return nothing for known options", func() {
+			path := writeConfig("github_organization: cloudposse\nlisten: \":301\"\n")
+			Expect(unknownOptions(path)).To(BeEmpty())
+		})
+	})
+
+	Describe("readConfig()", func() {
+		It("should warn about unknown options of flat file", func() {
+			hook := test.NewGlobal()
+			defer hook.Reset()
+
+			viper.SetConfigFile(writeConfig("github_organization: cloudposse\nbogus_option: true\n"))
+			Expect(readConfig()).To(BeNil())
+
+			warnings := []string{}
+			for _, entry := range hook.AllEntries() {
+				if entry.Level == log.WarnLevel {
+					warnings = append(warnings, entry.Message)
+				}
+			}
+			Expect(warnings).To(HaveLen(1))
+			Expect(warnings[0]).To(ContainSubstring("Unknown option bogus_option"))
+		})
+
+		It("should reject unknown keys of nested file", func() {
+			viper.SetConfigFile(writeConfig("version: 2\ngithub:\n  bogus_option: true\n"))
+			Expect(readConfig()).NotTo(BeNil())
+		})
+	})
+})
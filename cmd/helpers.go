@@ -19,10 +19,12 @@
 package cmd
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"github.com/terjekv/github-authorized-keys/model/linux"
 )
 
 type flag struct {
@@ -67,6 +69,104 @@ func fixStringSlice(s string) []string {
 	return result
 }
 
+// listOption - return {option} given as list or as comma separated string
+func listOption(option string) []string {
+	switch value := viper.Get(option).(type) {
+	case []string:
+		return value
+	case []interface{}:
+		result := []string{}
+		for _, item := range value {
+			result = append(result, fmt.Sprint(item))
+		}
+		return result
+	default:
+		return fixStringSlice(viper.GetString(option))
+	}
+}
+
+// stringMap - convert map decoded from config file to map of strings, ok is false if {value} is not a map
+func stringMap(value interface{}) (map[string]string, bool) {
+	result := map[string]string{}
+	switch typed := value.(type) {
+	case map[string]string:
+		for key, item := range typed {
+			result[key] = item
+		}
+	case map[string]interface{}:
+		for key, item := range typed {
+			result[key] = fmt.Sprint(item)
+		}
+	case map[interface{}]interface{}:
+		for key, item := range typed {
+			result[fmt.Sprint(key)] = fmt.Sprint(item)
+		}
+	default:
+		return nil, false
+	}
+	return result, true
+}
+
+// parsePair - parse {value} given in key=value format
+func parsePair(value string) (string, string, error) {
+	key, val, found := strings.Cut(value, "=")
+	if !found || key == "" {
+		return "", "", fmt.Errorf("%q should be in key=value format", value)
+	}
+	return key, val, nil
+}
+
+// mapOption - return {option} given as map or as comma separated key=value pairs
+func mapOption(option string) (map[string]string, error) {
+	value := viper.Get(option)
+	if result, ok := stringMap(value); ok {
+		return result, nil
+	}
+
+	result := map[string]string{}
+	for _, pair := range fixStringSlice(viper.GetString(option)) {
+		key, val, err := parsePair(pair)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", option, err)
+		}
+		result[key] = val
+	}
+	return result, nil
+}
+
+// substitutionsOption - return {option} given as list of from/to maps, list of from=to strings
+// or comma separated from=to pairs
+func substitutionsOption(option string) ([]linux.Substitution, error) {
+	var items []interface{}
+	switch value := viper.Get(option).(type) {
+	case []map[string]string:
+		for _, item := range value {
+			items = append(items, item)
+		}
+	case []interface{}:
+		items = value
+	default:
+		for _, item := range fixStringSlice(viper.GetString(option)) {
+			items = append(items, item)
+		}
+	}
+
+	result := []linux.Substitution{}
+	for _, item := range items {
+		if pair, ok := stringMap(item); ok {
+			result = append(result, linux.Substitution{From: pair["from"], To: pair["to"]})
+			continue
+		}
+
+		from, to, err := parsePair(fmt.Sprint(item))
+		if err != nil {
+			return nil, fmt.Errorf("%v: %v", option, err)
+		}
+		result = append(result, linux.Substitution{From: from, To: to})
+	}
+	return result, nil
+}
+
 func mask(source string) string {
 	length := len(source)
 	shown := length / 8
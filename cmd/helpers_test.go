@@ -0,0 +1,85 @@
+/*
+ * Github Authorized Keys - Use GitHub teams to manage system user accounts and authorized_keys
+ *
+ * Copyright 2016 Cloud Posse, LLC <hello@cloudposse.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/viper"
+	"github.com/terjekv/github-authorized-keys/model/linux"
+)
+
+var _ = Describe("Structured options", func() {
+	const option = "test_structured_option"
+
+	AfterEach(func() {
+		viper.Set(option, nil)
+	})
+
+	Describe("listOption()", func() {
+		It("should split comma separated string", func() {
+			viper.Set(option, "wheel,adm")
+			Expect(listOption(option)).To(Equal([]string{"wheel", "adm"}))
+		})
+
+		It("should keep list from config file", func() {
+			viper.Set(option, []string{"wheel", "a,b"})
+			Expect(listOption(option)).To(Equal([]string{"wheel", "a,b"}))
+		})
+
+		It("should return empty list for unset option", func() {
+			Expect(listOption(option)).To(BeEmpty())
+		})
+	})
+
+	Describe("mapOption()", func() {
+		It("should parse comma separated key=value pairs", func() {
+			viper.Set(option, "alice=al,john-doe=jdoe")
+			Expect(mapOption(option)).To(Equal(map[string]string{"alice": "al", "john-doe": "jdoe"}))
+		})
+
+		It("should keep values with separators from config file", func() {
+			viper.Set(option, map[string]string{"alice": "a=l,x"})
+			Expect(mapOption(option)).To(Equal(map[string]string{"alice": "a=l,x"}))
+		})
+
+		It("should reject pair without value separator", func() {
+			viper.Set(option, "alice")
+			_, err := mapOption(option)
+			Expect(err).To(MatchError(ContainSubstring("should be in key=value format")))
+		})
+	})
+
+	Describe("substitutionsOption()", func() {
+		It("should parse comma separated from=to pairs in order", func() {
+			viper.Set(option, ".=-,-=_")
+			Expect(substitutionsOption(option)).To(Equal([]linux.Substitution{{From: ".", To: "-"}, {From: "-", To: "_"}}))
+		})
+
+		It("should keep separators in from/to maps from config file", func() {
+			viper.Set(option, []map[string]string{{"from": "=", "to": ","}, {"from": ",", "to": "="}})
+			Expect(substitutionsOption(option)).To(Equal([]linux.Substitution{{From: "=", To: ","}, {From: ",", To: "="}}))
+		})
+
+		It("should accept maps decoded as generic values", func() {
+			viper.Set(option, []interface{}{map[string]interface{}{"from": ".", "to": "-"}})
+			Expect(substitutionsOption(option)).To(Equal([]linux.Substitution{{From: ".", To: "-"}}))
+		})
+	})
+})
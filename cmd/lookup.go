@@ -0,0 +1,190 @@
+/*
+ * Github Authorized Keys - Use GitHub teams to manage system user accounts and authorized_keys
+ *
+ * Copyright 2016 Cloud Posse, LLC <hello@cloudposse.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// LookupTimeoutDefault - default timeout of key server request in seconds
+const LookupTimeoutDefault = int64(10)
+
+var lookupFlags = []flag{
+	{"", "string", "lookup_url", "http://localhost:301", "Key server URL      ( environment variable LOOKUP_URL could be used instead )"},
+	{"", "string", "lookup_socket", "", "Key server socket   ( environment variable LOOKUP_SOCKET could be used instead )"},
+	{"", "string", "lookup_tls_ca_file", "", "Server CA file      ( environment variable LOOKUP_TLS_CA_FILE could be used instead )"},
+	{"", "string", "lookup_tls_cert_file", "", "Client certificate  ( environment variable LOOKUP_TLS_CERT_FILE could be used instead )"},
+	{"", "string", "lookup_tls_key_file", "", "Client private key  ( environment variable LOOKUP_TLS_KEY_FILE could be used instead )"},
+	{"", "string", "lookup_tls_server_name", "", "Server name         ( environment variable LOOKUP_TLS_SERVER_NAME could be used instead )"},
+	{"", "int64", "lookup_timeout", LookupTimeoutDefault, "Request timeout     ( environment variable LOOKUP_TIMEOUT could be used instead )"},
+}
+
+// lookupCmd - print authorized keys of user fetched from key server, designed to be used as sshd AuthorizedKeysCommand
+var lookupCmd = &cobra.Command{
+	Use:   "lookup <user> [fingerprint] [type]",
+	Short: "Print authorized keys of user fetched from key server",
+	Long: `
+Print authorized keys of user fetched from key server.
+When key fingerprint (and optionally key type) is passed only the matching key is printed.
+
+Designed to be used as sshd AuthorizedKeysCommand:
+  AuthorizedKeysCommand /usr/bin/github-authorized-keys lookup %u
+or, to fetch only the key offered by client:
+  AuthorizedKeysCommand /usr/bin/github-authorized-keys lookup %u %f %t
+`,
+	Args:          cobra.RangeArgs(1, 3),
+	PreRun:        bindLookupFlags,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newLookupClient()
+		if err != nil {
+			return err
+		}
+
+		path := "/user/" + url.PathEscape(args[0]) + "/authorized_keys"
+		if len(args) > 1 {
+			query := url.Values{"fingerprint": {args[1]}}
+			if len(args) > 2 {
+				query.Set("type", args[2])
+			}
+			path = "/user/" + url.PathEscape(args[0]) + "/key?" + query.Encode()
+		}
+
+		response, err := client.get(path)
+		if err != nil {
+			return err
+		}
+		defer response.Body.Close()
+
+		// Unknown user has no keys, sshd should just deny access
+		if response.StatusCode == http.StatusNotFound {
+			return nil
+		}
+
+		if response.StatusCode != http.StatusOK {
+			return fmt.Errorf("key server responded with %v", response.Status)
+		}
+
+		_, err = io.Copy(os.Stdout, response.Body)
+		return err
+	},
+}
+
+// lookupClient - HTTP client of key server configured by lookup_* options
+type lookupClient struct {
+	baseURL string
+	client  *http.Client
+}
+
+func (c *lookupClient) get(path string) (*http.Response, error) {
+	return c.client.Get(c.baseURL + path)
+}
+
+func newLookupClient() (*lookupClient, error) {
+	transport := &http.Transport{}
+	baseURL := strings.TrimRight(viper.GetString("lookup_url"), "/")
+
+	if socket := viper.GetString("lookup_socket"); socket != "" {
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+		}
+		baseURL = "http://localhost"
+	}
+
+	if strings.HasPrefix(baseURL, "https://") {
+		tlsConfig, err := lookupTLSConfig()
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	return &lookupClient{
+		baseURL: baseURL,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   time.Duration(viper.GetInt64("lookup_timeout")) * time.Second,
+		},
+	}, nil
+}
+
+func lookupTLSConfig() (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: viper.GetString("lookup_tls_server_name"),
+	}
+
+	if caFile := viper.GetString("lookup_tls_ca_file"); caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificates found in " + caFile)
+		}
+	}
+
+	certFile, keyFile := viper.GetString("lookup_tls_cert_file"), viper.GetString("lookup_tls_key_file")
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("both lookup tls certificate file and lookup tls key file are required")
+	}
+
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// bindLookupFlags - bind lookup_* options to flags of running command,
+// as lookup flags are shared between subcommands and viper keeps only the last bound flag
+func bindLookupFlags(cmd *cobra.Command, _ []string) {
+	for _, f := range lookupFlags {
+		viper.BindPFlag(f.option, cmd.Flags().Lookup(f.flag()))
+	}
+}
+
+func init() {
+	for _, f := range lookupFlags {
+		createCmdFlags(lookupCmd, f)
+	}
+
+	RootCmd.AddCommand(lookupCmd)
+}
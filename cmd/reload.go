@@ -0,0 +1,90 @@
+/*
+ * Github Authorized Keys - Use GitHub teams to manage system user accounts and authorized_keys
+ *
+ * Copyright 2016 Cloud Posse, LLC <hello@cloudposse.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/fsnotify/fsnotify"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/terjekv/github-authorized-keys/config"
+)
+
+// reloadMutex - serializes reloads triggered by SIGHUP and config file watcher
+var reloadMutex sync.Mutex
+
+// watchConfig - reload config into {holder} on SIGHUP and when config file changes
+func watchConfig(holder *config.Holder) {
+	logger := log.WithFields(log.Fields{"class": "RootCmd", "method": "watchConfig"})
+
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	go func() {
+		for range hangup {
+			logger.Info("Received SIGHUP, reloading config")
+			reloadConfig(holder)
+		}
+	}()
+
+	if viper.ConfigFileUsed() != "" {
+		viper.OnConfigChange(func(event fsnotify.Event) {
+			logger.Infof("Config file %v changed, reloading config", event.Name)
+			reloadConfig(holder)
+		})
+		viper.WatchConfig()
+	}
+}
+
+// reloadConfig - rebuild config and swap it into {holder} if it is valid, otherwise keep current one
+func reloadConfig(holder *config.Holder) {
+	logger := log.WithFields(log.Fields{"class": "RootCmd", "method": "reloadConfig"})
+
+	reloadMutex.Lock()
+	defer reloadMutex.Unlock()
+
+	if err := readConfig(); err != nil {
+		logger.Errorf("Unable to read config file, keep current config: %v", err)
+		return
+	}
+
+	cfg, err := loadConfig()
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		logger.Errorf("Invalid config, keep current config: %v", err)
+		return
+	}
+
+	if err := validateSystem(cfg); err != nil {
+		logger.Warnf("Config does not match the host: %v", err)
+	}
+
+	for _, option := range holder.Load().RestartRequired(cfg) {
+		logger.Warnf("Config: %v changed, restart is required to apply it", option)
+	}
+
+	holder.Store(cfg)
+	logConfig(cfg)
+	logger.Info("Config reloaded")
+}
@@ -0,0 +1,81 @@
+/*
+ * Github Authorized Keys - Use GitHub teams to manage system user accounts and authorized_keys
+ *
+ * Copyright 2016 Cloud Posse, LLC <hello@cloudposse.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/spf13/viper"
+	"github.com/terjekv/github-authorized-keys/config"
+)
+
+var _ = Describe("reloadConfig()", func() {
+	var (
+		dir    string
+		holder *config.Holder
+	)
+
+	valid := "github_api_token: secret\ngithub_organization: cloudposse\ngithub_admin_team_name: ssh\nlinux_user_del_tpl: userdel {username}\n"
+
+	reload := func(content string) {
+		path := filepath.Join(dir, "config.yaml")
+		Expect(ioutil.WriteFile(path, []byte(content), 0600)).To(BeNil())
+		viper.SetConfigFile(path)
+		reloadConfig(holder)
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "gak-reload")
+		Expect(err).To(BeNil())
+
+		holder = config.NewHolder(config.Config{GithubOrganization: "previous"})
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("should store valid config with resolved templates", func() {
+		reload(valid)
+
+		cfg := holder.Load()
+		Expect(cfg.GithubOrganization).To(Equal("cloudposse"))
+		Expect(cfg.Templates["linux_user_del_tpl"]).To(Equal([]string{"userdel", "{username}"}))
+		Expect(cfg.Templates).To(HaveKey("linux_user_add_tpl"))
+	})
+
+	It("should keep previous config when new one is invalid", func() {
+		reload(valid)
+		reload("github_api_token: secret\ngithub_organization: terjekv\n")
+
+		Expect(holder.Load().GithubOrganization).To(Equal("cloudposse"))
+	})
+
+	It("should keep previous config when file could not be read", func() {
+		reload(valid)
+		reload("version: 2\ngithub:\n  organization: terjekv\n  bogus_option: true\n")
+
+		Expect(holder.Load().GithubOrganization).To(Equal("cloudposse"))
+	})
+})
@@ -19,13 +19,18 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"github.com/terjekv/github-authorized-keys/api"
+	"github.com/terjekv/github-authorized-keys/audit"
 	"github.com/terjekv/github-authorized-keys/config"
 	"github.com/terjekv/github-authorized-keys/jobs"
 	"github.com/terjekv/github-authorized-keys/server"
@@ -33,14 +38,24 @@ import (
 
 var cfgFile string
 
+// configErr - error of reading config file, reported by commands that need config
+var configErr error
+
 // ETCDTTLDefault - default ttl - 1day in seconds = 24 hours * 60 minutes * 60 seconds
 const ETCDTTLDefault = int64(24 * 60 * 60)
 
+// RedisTTLDefault - default ttl - 1day in seconds = 24 hours * 60 minutes * 60 seconds
+const RedisTTLDefault = int64(24 * 60 * 60)
+
+// ShutdownTimeoutDefault - time in seconds to drain requests and finish sync on shutdown
+const ShutdownTimeoutDefault = int64(30)
+
 // SyncUsersIntervalDefault - default interval between synchronize users - 5 minutes in seconds = 5 minutes * 60 seconds
 const SyncUsersIntervalDefault = int64(5 * 60)
 
 var flags = []flag{
 	{"a", "string", "github_api_token", "", "Github API token       ( environment variable GITHUB_API_TOKEN could be used instead ) (read more https://github.com/blog/1509-personal-api-tokens)"},
+	{"", "string", "github_api_token_file", "", "Github API token file  ( environment variable GITHUB_API_TOKEN_FILE could be used instead )"},
 	{"o", "string", "github_organization", "", "Github organization    ( environment variable GITHUB_ORGANIZATION could be used instead )"},
 	{"n", "string", "github_admin_team_name", "", "Github admin team name ( environment variable GITHUB_ADMIN_TEAM_NAME could be used instead )"},
 	{"N", "string", "github_user_team_name", "", "Github user team name  ( environment variable GITHUB_USER_TEAM_NAME could be used instead )"},
@@ -52,14 +67,65 @@ var flags = []flag{
 
 	{"s", "string", "sync_users_shell", "/bin/bash", "User shell 	    ( environment variable SYNC_USERS_SHELL could be used instead )"},
 	{"r", "string", "sync_users_root", "/", "Root directory 	    ( environment variable SYNC_USERS_ROOT could be used instead )"},
+	{"", "bool", "sync_users_gecos", false, "GECOS from GitHub   ( environment variable SYNC_USERS_GECOS could be used instead )"},
+	{"", "string", "linux_user_backend", "command", "Users backend       ( environment variable LINUX_USER_BACKEND could be used instead )"},
+	{"", "string", "linux_profile", "auto", "Templates profile   ( environment variable LINUX_PROFILE could be used instead )"},
+	{"", "int64", "sync_users_uid_base", int64(0), "First derived uid   ( environment variable SYNC_USERS_UID_BASE could be used instead )"},
+	{"", "int64", "sync_users_uid_span", int64(0), "Derived uids count  ( environment variable SYNC_USERS_UID_SPAN could be used instead )"},
+	{"", "string", "sync_users_uid_collision", "probe", "On uid collision    ( environment variable SYNC_USERS_UID_COLLISION could be used instead )"},
+	{"", "strings", "sync_users_name_overrides", []string{}, "CSV login=name     ( environment variable SYNC_USERS_NAME_OVERRIDES could be used instead )"},
+	{"", "strings", "sync_users_name_substitutions", []string{}, "CSV from=to        ( environment variable SYNC_USERS_NAME_SUBSTITUTIONS could be used instead )"},
+	{"", "string", "sync_users_name_prefix", "", "User name prefix    ( environment variable SYNC_USERS_NAME_PREFIX could be used instead )"},
+	{"", "string", "sync_users_name_suffix", "", "User name suffix    ( environment variable SYNC_USERS_NAME_SUFFIX could be used instead )"},
+	{"", "int", "sync_users_name_max_length", 0, "User name max len   ( environment variable SYNC_USERS_NAME_MAX_LENGTH could be used instead )"},
+	{"", "string", "sync_users_state_file", "/var/lib/github-authorized-keys/users.json", "Managed users state ( environment variable SYNC_USERS_STATE_FILE could be used instead )"},
+	{"", "string", "sync_users_rename_policy", "rename", "On GitHub rename    ( environment variable SYNC_USERS_RENAME_POLICY could be used instead )"},
 	{"c", "int64", "sync_users_interval", SyncUsersIntervalDefault, "Sync each x sec     ( environment variable SYNC_USERS_INTERVAL could be used instead )"},
 
 	{"e", "strings", "etcd_endpoint", []string{}, "CSV etcd endpoints  ( environment variable ETCD_ENDPOINT could be used instead )"},
 	{"p", "string", "etcd_prefix", "/github-authorized-keys", "Path for etcd data  ( environment variable ETCD_PREFIX could be used instead )"},
 	{"t", "int64", "etcd_ttl", ETCDTTLDefault, "ETCD value's ttl    ( environment variable ETCD_TTL could be used instead )"},
 
+	{"", "strings", "redis_endpoint", []string{}, "CSV redis host:port ( environment variable REDIS_ENDPOINT could be used instead )"},
+	{"", "string", "redis_master_name", "", "Sentinel master     ( environment variable REDIS_MASTER_NAME could be used instead )"},
+	{"", "string", "redis_password", "", "Redis password      ( environment variable REDIS_PASSWORD could be used instead )"},
+	{"", "string", "redis_password_file", "", "Redis password file ( environment variable REDIS_PASSWORD_FILE could be used instead )"},
+	{"", "int", "redis_db", 0, "Redis database      ( environment variable REDIS_DB could be used instead )"},
+	{"", "bool", "redis_tls", false, "Connect with TLS    ( environment variable REDIS_TLS could be used instead )"},
+	{"", "string", "redis_tls_ca_file", "", "Redis TLS CA file   ( environment variable REDIS_TLS_CA_FILE could be used instead )"},
+	{"", "string", "redis_prefix", "github-authorized-keys:", "Redis key prefix    ( environment variable REDIS_PREFIX could be used instead )"},
+	{"", "int64", "redis_ttl", RedisTTLDefault, "Redis value's ttl   ( environment variable REDIS_TTL could be used instead )"},
+
+	{"", "int64", "cache_max_staleness", int64(0), "Max cached keys age ( environment variable CACHE_MAX_STALENESS could be used instead )"},
+	{"", "int64", "cache_max_staleness_admin", int64(0), "Max age for admins  ( environment variable CACHE_MAX_STALENESS_ADMIN could be used instead )"},
+	{"", "int64", "cache_max_staleness_user", int64(0), "Max age for users   ( environment variable CACHE_MAX_STALENESS_USER could be used instead )"},
+	{"", "string", "cache_encryption_key_file", "", "Cache AES key file  ( environment variable CACHE_ENCRYPTION_KEY_FILE could be used instead )"},
+	{"", "string", "cache_signing_key_file", "", "Cache signing key   ( environment variable CACHE_SIGNING_KEY_FILE could be used instead )"},
+	{"", "string", "cache_verify_key_file", "", "Cache verify key    ( environment variable CACHE_VERIFY_KEY_FILE could be used instead )"},
+
 	{"d", "bool", "integrate_ssh", false, "Integrate with ssh  ( environment variable INTEGRATE_SSH could be used instead )"},
 	{"l", "string", "listen", ":301", "Listen              ( environment variable LISTEN could be used instead )"},
+	{"", "string", "listen_socket", "", "Listen unix socket  ( environment variable LISTEN_SOCKET could be used instead )"},
+	{"", "string", "listen_socket_owner", "", "Socket owner        ( environment variable LISTEN_SOCKET_OWNER could be used instead )"},
+	{"", "string", "listen_socket_group", "", "Socket group        ( environment variable LISTEN_SOCKET_GROUP could be used instead )"},
+	{"", "string", "listen_socket_mode", "0660", "Socket mode         ( environment variable LISTEN_SOCKET_MODE could be used instead )"},
+	{"", "string", "tls_cert_file", "", "TLS certificate     ( environment variable TLS_CERT_FILE could be used instead )"},
+	{"", "string", "tls_key_file", "", "TLS private key     ( environment variable TLS_KEY_FILE could be used instead )"},
+	{"", "string", "tls_client_ca_file", "", "TLS client CA file  ( environment variable TLS_CLIENT_CA_FILE could be used instead )"},
+
+	{"", "string", "audit_output", "none", "Audit output        ( environment variable AUDIT_OUTPUT could be used instead )"},
+	{"", "string", "audit_file", "", "Audit log file      ( environment variable AUDIT_FILE could be used instead )"},
+	{"", "int", "audit_file_max_size", 100, "Rotate at size (MB) ( environment variable AUDIT_FILE_MAX_SIZE could be used instead )"},
+	{"", "int", "audit_file_max_backups", 10, "Rotated files kept  ( environment variable AUDIT_FILE_MAX_BACKUPS could be used instead )"},
+	{"", "int", "audit_file_max_age", 0, "Rotated files days  ( environment variable AUDIT_FILE_MAX_AGE could be used instead )"},
+
+	{"", "int", "rate_limit_client", 120, "Requests/min/client ( environment variable RATE_LIMIT_CLIENT could be used instead )"},
+	{"", "int", "rate_limit_client_burst", 20, "Client burst        ( environment variable RATE_LIMIT_CLIENT_BURST could be used instead )"},
+	{"", "int", "rate_limit_backend", 0, "GitHub lookups/min  ( environment variable RATE_LIMIT_BACKEND could be used instead )"},
+
+	{"", "bool", "users_api_tcp", false, "Users API on TCP    ( environment variable USERS_API_TCP could be used instead )"},
+
+	{"", "int64", "shutdown_timeout", ShutdownTimeoutDefault, "Shutdown timeout    ( environment variable SHUTDOWN_TIMEOUT could be used instead )"},
 }
 
 // RootCmd represents the base command when called without any subcommands
@@ -79,76 +145,329 @@ Config:
 `,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		logger := log.WithFields(log.Fields{"class": "RootCmd", "method": "RunE"})
-		// @TODO Support viper duration type
-		etcdTTL, err := time.ParseDuration(viper.GetString("etcd_ttl") + "s")
 
+		if configErr != nil {
+			return configErr
+		}
+
+		cfg, err := loadConfig()
 		if err != nil {
 			return err
 		}
 
-		cfg := config.Config{
-			GithubAPIToken:     viper.GetString("github_api_token"),
-			GithubOrganization: viper.GetString("github_organization"),
-			//			GithubTeamID:       viper.GetInt("github_team_id"),
+		logConfig(cfg)
 
-			GithubAdminTeamName: viper.GetString("github_admin_team_name"),
-			GithubUserTeamName:  viper.GetString("github_user_team_name"),
-			GithubAdminTeamID:   viper.GetInt("github_admin_team_id"),
-			GithubUserTeamID:    viper.GetInt("github_user_team_id"),
+		err = cfg.Validate()
 
-			EtcdEndpoints: fixStringSlice(viper.GetString("etcd_endpoint")),
-			EtcdPrefix:    viper.GetString("etcd_prefix"),
-			EtcdTTL:       etcdTTL,
+		if err != nil {
+			return err
+		}
 
-			//			UserGID:    viper.GetString("sync_users_gid"),
+		if err := validateSystem(cfg); err != nil {
+			logger.Warnf("Config does not match the host: %v", err)
+		}
 
-			UserAdminGroups: fixStringSlice(viper.GetString("sync_users_admin_groups")),
-			UserUserGroups:  fixStringSlice(viper.GetString("sync_users_users_groups")),
+		// Signals are handled from the start, so initial sync is never interrupted
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+		defer stop()
+
+		// Audit is opened before initial sync, so account events are recorded too
+		err = audit.Setup(audit.Options{
+			Output:     cfg.AuditOutput,
+			File:       cfg.AuditFile,
+			MaxSize:    cfg.AuditFileMaxSize,
+			MaxBackups: cfg.AuditFileMaxBackups,
+			MaxAge:     cfg.AuditFileMaxAge,
+		})
+		if err != nil {
+			return fmt.Errorf("unable to open audit output: %v", err)
+		}
+		defer audit.Close()
 
-			UserShell: viper.GetString("sync_users_shell"),
-			Root:      viper.GetString("sync_users_root"),
-			Interval:  uint64(viper.GetInt64("sync_users_interval")),
+		holder := config.NewHolder(cfg)
+		watchConfig(holder)
 
-			IntegrateWithSSH: viper.GetBool("integrate_ssh"),
+		jobs.Run(holder)
+		server.Run(ctx, holder)
 
-			Listen: viper.GetString("listen"),
+		logger.Info("Waiting for users sync in progress")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), holder.Load().ShutdownTimeout)
+		defer cancel()
+		if err := jobs.Stop(shutdownCtx); err != nil {
+			logger.Errorf("Users sync did not finish in time: %v", err)
 		}
 
-		logger.Infof("Config: GithubAPIToken - %v", mask(cfg.GithubAPIToken))
-		logger.Infof("Config: GithubOrganization - %v", mask(cfg.GithubOrganization))
-		logger.Infof("Config: GithubAdminTeamName - %v", mask(cfg.GithubAdminTeamName))
-		logger.Infof("Config: GithubUserTeamName - %v", mask(cfg.GithubUserTeamName))
-		logger.Infof("Config: GithubAdminTeamID - %v", mask(fmt.Sprintf("%d", cfg.GithubAdminTeamID)))
-		logger.Infof("Config: GithubUserTeamID - %v", mask(fmt.Sprintf("%d", cfg.GithubUserTeamID)))
-		//		logger.Infof("Config: GithubTeamID - %v", mask(string(cfg.GithubTeamID)))
-		logger.Infof("Config: EtcdEndpoints - %v", cfg.EtcdEndpoints)
-		logger.Infof("Config: EtcdPrefix - %v", cfg.EtcdPrefix)
-		logger.Infof("Config: EtcdTTL - %v seconds", cfg.EtcdTTL)
-		//		logger.Infof("Config: UserGID - %v", cfg.UserGID)
-		logger.Infof("Config: UserAdminGroups - %v", cfg.UserAdminGroups)
-		logger.Infof("Config: UserUserGroups - %v", cfg.UserUserGroups)
-		logger.Infof("Config: UserShell - %v", cfg.UserShell)
-		logger.Infof("Config: Root - %v", cfg.Root)
-		logger.Infof("Config: Interval - %v seconds", cfg.Interval)
-		logger.Infof("Config: IntegrateWithSSH - %v", cfg.IntegrateWithSSH)
-		logger.Infof("Config: Listen - %v", cfg.Listen)
+		return nil
+	},
+}
 
-		err = cfg.Validate()
+// loadConfig - build config from flags, environment variables and config file
+func loadConfig() (config.Config, error) {
+	// @TODO Support viper duration type
+	etcdTTL, err := time.ParseDuration(viper.GetString("etcd_ttl") + "s")
+
+	if err != nil {
+		return config.Config{}, err
+	}
+
+	redisTTL, err := time.ParseDuration(viper.GetString("redis_ttl") + "s")
+
+	if err != nil {
+		return config.Config{}, err
+	}
+
+	githubAPIToken, githubAPITokenFile, err := secretOption("github_api_token")
+
+	if err != nil {
+		return config.Config{}, err
+	}
+
+	redisPassword, redisPasswordFile, err := secretOption("redis_password")
+
+	if err != nil {
+		return config.Config{}, err
+	}
+
+	cacheEncryptionKeyFile, err := config.ResolveSecretPath(viper.GetString("cache_encryption_key_file"))
+
+	if err != nil {
+		return config.Config{}, err
+	}
+
+	cacheSigningKeyFile, err := config.ResolveSecretPath(viper.GetString("cache_signing_key_file"))
 
-		if err == nil {
-			jobs.Run(cfg)
-			server.Run(cfg)
+	if err != nil {
+		return config.Config{}, err
+	}
+
+	cacheVerifyKeyFile, err := config.ResolveSecretPath(viper.GetString("cache_verify_key_file"))
+
+	if err != nil {
+		return config.Config{}, err
+	}
+
+	nameOverrides, err := mapOption("sync_users_name_overrides")
+
+	if err != nil {
+		return config.Config{}, err
+	}
+
+	nameSubstitutions, err := substitutionsOption("sync_users_name_substitutions")
+
+	if err != nil {
+		return config.Config{}, err
+	}
+
+	cfg := config.Config{
+		GithubAPIToken:     githubAPIToken,
+		GithubAPITokenFile: githubAPITokenFile,
+		GithubOrganization: viper.GetString("github_organization"),
+		GithubAPIPageSize:  viper.GetInt("github_api_max_page_size"),
+		//			GithubTeamID:       viper.GetInt("github_team_id"),
+
+		GithubAdminTeamName: viper.GetString("github_admin_team_name"),
+		GithubUserTeamName:  viper.GetString("github_user_team_name"),
+		GithubAdminTeamID:   viper.GetInt("github_admin_team_id"),
+		GithubUserTeamID:    viper.GetInt("github_user_team_id"),
+
+		EtcdEndpoints: listOption("etcd_endpoint"),
+		EtcdPrefix:    viper.GetString("etcd_prefix"),
+		EtcdTTL:       etcdTTL,
+
+		RedisEndpoints:    listOption("redis_endpoint"),
+		RedisMasterName:   viper.GetString("redis_master_name"),
+		RedisPassword:     redisPassword,
+		RedisPasswordFile: redisPasswordFile,
+		RedisDB:           viper.GetInt("redis_db"),
+		RedisTLS:          viper.GetBool("redis_tls"),
+		RedisTLSCAFile:    viper.GetString("redis_tls_ca_file"),
+		RedisPrefix:       viper.GetString("redis_prefix"),
+		RedisTTL:          redisTTL,
+
+		CacheMaxStaleness:      time.Duration(viper.GetInt64("cache_max_staleness")) * time.Second,
+		CacheMaxStalenessAdmin: time.Duration(viper.GetInt64("cache_max_staleness_admin")) * time.Second,
+		CacheMaxStalenessUser:  time.Duration(viper.GetInt64("cache_max_staleness_user")) * time.Second,
+
+		CacheEncryptionKeyFile: cacheEncryptionKeyFile,
+		CacheSigningKeyFile:    cacheSigningKeyFile,
+		CacheVerifyKeyFile:     cacheVerifyKeyFile,
+
+		//			UserGID:    viper.GetString("sync_users_gid"),
+
+		UserAdminGroups: listOption("sync_users_admin_groups"),
+		UserUserGroups:  listOption("sync_users_users_groups"),
+
+		UserShell:    viper.GetString("sync_users_shell"),
+		UserGecos:    viper.GetBool("sync_users_gecos"),
+		UserBackend:  viper.GetString("linux_user_backend"),
+		LinuxProfile: viper.GetString("linux_profile"),
+
+		UserUIDBase:      uint64(viper.GetInt64("sync_users_uid_base")),
+		UserUIDSpan:      uint64(viper.GetInt64("sync_users_uid_span")),
+		UserUIDCollision: viper.GetString("sync_users_uid_collision"),
+
+		UserNameOverrides:     nameOverrides,
+		UserNameSubstitutions: nameSubstitutions,
+		UserNamePrefix:        viper.GetString("sync_users_name_prefix"),
+		UserNameSuffix:        viper.GetString("sync_users_name_suffix"),
+		UserNameMaxLength:     viper.GetInt("sync_users_name_max_length"),
+
+		StateFile:    viper.GetString("sync_users_state_file"),
+		RenamePolicy: viper.GetString("sync_users_rename_policy"),
+		Root:         viper.GetString("sync_users_root"),
+		Interval:     uint64(viper.GetInt64("sync_users_interval")),
+
+		IntegrateWithSSH:      viper.GetBool("integrate_ssh"),
+		AuthorizedKeysCommand: viper.GetString("authorized_keys_command_tpl"),
+
+		Listen: viper.GetString("listen"),
+
+		ListenSocket:      viper.GetString("listen_socket"),
+		ListenSocketOwner: viper.GetString("listen_socket_owner"),
+		ListenSocketGroup: viper.GetString("listen_socket_group"),
+		ListenSocketMode:  viper.GetString("listen_socket_mode"),
+
+		TLSCertFile:     viper.GetString("tls_cert_file"),
+		TLSKeyFile:      viper.GetString("tls_key_file"),
+		TLSClientCAFile: viper.GetString("tls_client_ca_file"),
+
+		AuditOutput:         viper.GetString("audit_output"),
+		AuditFile:           viper.GetString("audit_file"),
+		AuditFileMaxSize:    viper.GetInt("audit_file_max_size"),
+		AuditFileMaxBackups: viper.GetInt("audit_file_max_backups"),
+		AuditFileMaxAge:     viper.GetInt("audit_file_max_age"),
+
+		RateLimitClient:      viper.GetInt("rate_limit_client"),
+		RateLimitClientBurst: viper.GetInt("rate_limit_client_burst"),
+		RateLimitBackend:     viper.GetInt("rate_limit_backend"),
+
+		UsersAPITCP: viper.GetBool("users_api_tcp"),
+
+		ShutdownTimeout: time.Duration(viper.GetInt64("shutdown_timeout")) * time.Second,
+	}
+
+	// Templates are resolved once, so sync and server never read options while config file is reloaded
+	options := map[string]interface{}{}
+	for _, key := range api.TemplateNames() {
+		if viper.IsSet(key) {
+			options[key] = viper.Get(key)
 		}
+	}
+	linux := api.NewConfiguredLinux(cfg)
+	cfg.Templates = linux.ResolveTemplates(options)
 
-		return err
-	},
+	return cfg, nil
+}
+
+// secretOption - return value of secret {option} and file it was read from.
+// Value is read from {option}_file, from {option} given as env:, file: or credential: reference,
+// or from systemd credential named as {option} when neither is set.
+func secretOption(option string) (value string, file string, err error) {
+	value = viper.GetString(option)
+	file = viper.GetString(option + "_file")
+
+	if value != "" && file != "" {
+		return "", "", fmt.Errorf("either %v or %v_file could be set, not both", option, option)
+	}
+
+	if file != "" {
+		if file, err = config.ResolveSecretPath(file); err != nil {
+			return "", "", err
+		}
+	} else if value == "" {
+		file = config.CredentialPath(option)
+	}
+
+	if file != "" {
+		value, err = config.ReadSecretFile(file)
+		if err != nil {
+			return "", "", fmt.Errorf("%v_file: %v", option, err)
+		}
+		return value, file, nil
+	}
+
+	value, err = config.ResolveSecret(value)
+	if err != nil {
+		return "", "", fmt.Errorf("%v: %v", option, err)
+	}
+	return value, "", nil
+}
+
+func logConfig(cfg config.Config) {
+	logger := log.WithFields(log.Fields{"class": "RootCmd", "method": "logConfig"})
+
+	logger.Infof("Config: GithubAPIToken - %v", mask(cfg.GithubAPIToken))
+	logger.Infof("Config: GithubAPITokenFile - %v", cfg.GithubAPITokenFile)
+	logger.Infof("Config: GithubOrganization - %v", mask(cfg.GithubOrganization))
+	logger.Infof("Config: GithubAdminTeamName - %v", mask(cfg.GithubAdminTeamName))
+	logger.Infof("Config: GithubUserTeamName - %v", mask(cfg.GithubUserTeamName))
+	logger.Infof("Config: GithubAdminTeamID - %v", mask(fmt.Sprintf("%d", cfg.GithubAdminTeamID)))
+	logger.Infof("Config: GithubUserTeamID - %v", mask(fmt.Sprintf("%d", cfg.GithubUserTeamID)))
+	//		logger.Infof("Config: GithubTeamID - %v", mask(string(cfg.GithubTeamID)))
+	logger.Infof("Config: EtcdEndpoints - %v", cfg.EtcdEndpoints)
+	logger.Infof("Config: EtcdPrefix - %v", cfg.EtcdPrefix)
+	logger.Infof("Config: EtcdTTL - %v seconds", cfg.EtcdTTL)
+	logger.Infof("Config: RedisEndpoints - %v", cfg.RedisEndpoints)
+	logger.Infof("Config: RedisMasterName - %v", cfg.RedisMasterName)
+	logger.Infof("Config: RedisPassword - %v", mask(cfg.RedisPassword))
+	logger.Infof("Config: RedisPasswordFile - %v", cfg.RedisPasswordFile)
+	logger.Infof("Config: RedisDB - %v", cfg.RedisDB)
+	logger.Infof("Config: RedisTLS - %v", cfg.RedisTLS)
+	logger.Infof("Config: RedisTLSCAFile - %v", cfg.RedisTLSCAFile)
+	logger.Infof("Config: RedisPrefix - %v", cfg.RedisPrefix)
+	logger.Infof("Config: RedisTTL - %v seconds", cfg.RedisTTL)
+	logger.Infof("Config: CacheMaxStaleness - %v", cfg.CacheMaxStaleness)
+	logger.Infof("Config: CacheMaxStalenessAdmin - %v", cfg.CacheMaxStalenessAdmin)
+	logger.Infof("Config: CacheMaxStalenessUser - %v", cfg.CacheMaxStalenessUser)
+	logger.Infof("Config: CacheEncryptionKeyFile - %v", cfg.CacheEncryptionKeyFile)
+	logger.Infof("Config: CacheSigningKeyFile - %v", cfg.CacheSigningKeyFile)
+	logger.Infof("Config: CacheVerifyKeyFile - %v", cfg.CacheVerifyKeyFile)
+	//		logger.Infof("Config: UserGID - %v", cfg.UserGID)
+	logger.Infof("Config: UserAdminGroups - %v", cfg.UserAdminGroups)
+	logger.Infof("Config: UserUserGroups - %v", cfg.UserUserGroups)
+	logger.Infof("Config: UserShell - %v", cfg.UserShell)
+	logger.Infof("Config: UserGecos - %v", cfg.UserGecos)
+	logger.Infof("Config: UserBackend - %v", cfg.UserBackend)
+	logger.Infof("Config: UserUIDBase - %v", cfg.UserUIDBase)
+	logger.Infof("Config: UserUIDSpan - %v", cfg.UserUIDSpan)
+	logger.Infof("Config: UserUIDCollision - %v", cfg.UserUIDCollision)
+	logger.Infof("Config: UserNameOverrides - %v", cfg.UserNameOverrides)
+	logger.Infof("Config: UserNameSubstitutions - %v", cfg.UserNameSubstitutions)
+	logger.Infof("Config: UserNamePrefix - %v", cfg.UserNamePrefix)
+	logger.Infof("Config: UserNameSuffix - %v", cfg.UserNameSuffix)
+	logger.Infof("Config: UserNameMaxLength - %v", cfg.UserNameMaxLength)
+	logger.Infof("Config: StateFile - %v", cfg.StatePath())
+	logger.Infof("Config: RenamePolicy - %v", cfg.RenamePolicy)
+	linux := api.NewConfiguredLinux(cfg)
+	logger.Infof("Config: LinuxProfile - %v (%v)", cfg.LinuxProfile, linux.Profile().Name)
+	logger.Infof("Config: Root - %v", cfg.Root)
+	logger.Infof("Config: Interval - %v seconds", cfg.Interval)
+	logger.Infof("Config: IntegrateWithSSH - %v", cfg.IntegrateWithSSH)
+	logger.Infof("Config: Listen - %v", cfg.Listen)
+	logger.Infof("Config: ListenSocket - %v", cfg.ListenSocket)
+	logger.Infof("Config: ListenSocketOwner - %v", cfg.ListenSocketOwner)
+	logger.Infof("Config: ListenSocketGroup - %v", cfg.ListenSocketGroup)
+	logger.Infof("Config: ListenSocketMode - %v", cfg.ListenSocketMode)
+	logger.Infof("Config: TLSCertFile - %v", cfg.TLSCertFile)
+	logger.Infof("Config: TLSKeyFile - %v", cfg.TLSKeyFile)
+	logger.Infof("Config: TLSClientCAFile - %v", cfg.TLSClientCAFile)
+	logger.Infof("Config: AuditOutput - %v", cfg.AuditOutput)
+	logger.Infof("Config: AuditFile - %v", cfg.AuditFile)
+	logger.Infof("Config: AuditFileMaxSize - %v MB", cfg.AuditFileMaxSize)
+	logger.Infof("Config: AuditFileMaxBackups - %v", cfg.AuditFileMaxBackups)
+	logger.Infof("Config: AuditFileMaxAge - %v days", cfg.AuditFileMaxAge)
+	logger.Infof("Config: RateLimitClient - %v per minute", cfg.RateLimitClient)
+	logger.Infof("Config: RateLimitClientBurst - %v", cfg.RateLimitClientBurst)
+	logger.Infof("Config: RateLimitBackend - %v per minute", cfg.RateLimitBackend)
+	logger.Infof("Config: UsersAPITCP - %v", cfg.UsersAPITCP)
+	logger.Infof("Config: ShutdownTimeout - %v", cfg.ShutdownTimeout)
 }
 
 // Execute adds all child commands to the root command sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
 	if err := RootCmd.Execute(); err != nil {
-		fmt.Println(err)
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(-1)
 	}
 }
@@ -169,14 +488,18 @@ func init() {
 func initConfig() {
 	if cfgFile != "" { // enable ability to specify config file via flag
 		viper.SetConfigFile(cfgFile)
+	} else {
+		// SetConfigName resets config file set by flag, so it is used only for default search
+		viper.SetConfigName(".github-authorized-keys") // name of config file (without extension)
+		viper.AddConfigPath("$HOME")                   // adding home directory as first search path
 	}
 
-	viper.SetConfigName(".github-authorized-keys") // name of config file (without extension)
-	viper.AddConfigPath("$HOME")                   // adding home directory as first search path
-	viper.AutomaticEnv()                           // read in environment variables that match
+	viper.AutomaticEnv() // read in environment variables that match
 
 	// If a config file is found, read it in.
-	if err := viper.ReadInConfig(); err == nil {
-		fmt.Println("Using config file:", viper.ConfigFileUsed())
+	configErr = readConfig()
+	if configErr == nil && viper.ConfigFileUsed() != "" {
+		// Stdout is reserved for command output, e.g. keys printed by lookup command
+		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
 	}
 }
@@ -0,0 +1,101 @@
+/*
+ * Github Authorized Keys - Use GitHub teams to manage system user accounts and authorized_keys
+ *
+ * Copyright 2016 Cloud Posse, LLC <hello@cloudposse.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var usersJSON bool
+
+type userRecord struct {
+	Login      string     `json:"login"`
+	Role       string     `json:"role"`
+	Teams      []string   `json:"teams"`
+	Groups     []string   `json:"groups"`
+	Account    string     `json:"account"`
+	LastLookup *time.Time `json:"last_lookup"`
+}
+
+// usersCmd - print users that have access to this host as reported by key server
+var usersCmd = &cobra.Command{
+	Use:           "users",
+	Short:         "List users with access to this host",
+	Long:          "\nList members of configured GitHub teams with their role, linux account status and last key lookup time.\n",
+	Args:          cobra.NoArgs,
+	PreRun:        bindLookupFlags,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		client, err := newLookupClient()
+		if err != nil {
+			return err
+		}
+
+		response, err := client.get("/v1/users")
+		if err != nil {
+			return err
+		}
+		defer response.Body.Close()
+
+		if response.StatusCode != http.StatusOK {
+			return fmt.Errorf("key server responded with %v", response.Status)
+		}
+
+		if usersJSON {
+			_, err = io.Copy(os.Stdout, response.Body)
+			return err
+		}
+
+		users := []userRecord{}
+		if err := json.NewDecoder(response.Body).Decode(&users); err != nil {
+			return err
+		}
+
+		writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, "LOGIN\tROLE\tTEAMS\tGROUPS\tACCOUNT\tLAST LOOKUP")
+		for _, user := range users {
+			lastLookup := "never"
+			if user.LastLookup != nil {
+				lastLookup = user.LastLookup.Format(time.RFC3339)
+			}
+			fmt.Fprintf(writer, "%v\t%v\t%v\t%v\t%v\t%v\n",
+				user.Login, user.Role, strings.Join(user.Teams, ","), strings.Join(user.Groups, ","), user.Account, lastLookup)
+		}
+		return writer.Flush()
+	},
+}
+
+func init() {
+	for _, f := range lookupFlags {
+		createCmdFlags(usersCmd, f)
+	}
+	usersCmd.Flags().BoolVar(&usersJSON, "json", false, "Print raw JSON response")
+
+	RootCmd.AddCommand(usersCmd)
+}
@@ -20,15 +20,23 @@ package config
 
 import (
 	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/terjekv/github-authorized-keys/model/linux"
 )
 
 // Config - structure to store global configuration
 type Config struct {
 	GithubAPIToken     string
+	GithubAPITokenFile string
 	GithubOrganization string
+	GithubAPIPageSize  int
 
 	GithubAdminTeamName string
 	GithubAdminTeamID   int
@@ -39,16 +47,77 @@ type Config struct {
 	EtcdTTL       time.Duration
 	EtcdPrefix    string
 
+	RedisEndpoints    []string
+	RedisMasterName   string
+	RedisPassword     string
+	RedisPasswordFile string
+	RedisDB           int
+	RedisTLS          bool
+	RedisTLSCAFile    string
+	RedisPrefix       string
+	RedisTTL          time.Duration
+
+	CacheMaxStaleness      time.Duration
+	CacheMaxStalenessAdmin time.Duration
+	CacheMaxStalenessUser  time.Duration
+
+	CacheEncryptionKeyFile string
+	CacheSigningKeyFile    string
+	CacheVerifyKeyFile     string
+
 	UserAdminGroups []string
 	UserUserGroups  []string
 
-	UserShell string
-	Root      string
-	Interval  uint64
+	UserShell   string
+	UserBackend string
+	UserGecos   bool
+
+	UserUIDBase      uint64
+	UserUIDSpan      uint64
+	UserUIDCollision string
+
+	UserNameOverrides     map[string]string
+	UserNameSubstitutions []linux.Substitution
+	UserNamePrefix        string
+	UserNameSuffix        string
+	UserNameMaxLength     int
 
-	IntegrateWithSSH bool
+	StateFile    string
+	RenamePolicy string
+	LinuxProfile string
+	Root         string
+	Interval     uint64
+
+	// Templates - command templates by option name, resolved from options and profile when config is loaded
+	Templates map[string][]string
+
+	IntegrateWithSSH      bool
+	AuthorizedKeysCommand string
 
 	Listen string
+
+	ListenSocket      string
+	ListenSocketOwner string
+	ListenSocketGroup string
+	ListenSocketMode  string
+
+	TLSCertFile     string
+	TLSKeyFile      string
+	TLSClientCAFile string
+
+	AuditOutput         string
+	AuditFile           string
+	AuditFileMaxSize    int
+	AuditFileMaxBackups int
+	AuditFileMaxAge     int
+
+	RateLimitClient      int
+	RateLimitClientBurst int
+	RateLimitBackend     int
+
+	UsersAPITCP bool
+
+	ShutdownTimeout time.Duration
 }
 
 // Validate - process validation of config values
@@ -64,6 +133,137 @@ func (c Config) Validate() (err error) {
 	// Validate Github Team exists
 	if c.GithubAdminTeamName == "" && c.GithubUserTeamName == "" {
 		err = errors.New("either a github admin team name or a github user team name is required")
+		return
+	}
+
+	// Only one fallback cache backend could be used
+	if len(c.EtcdEndpoints) > 0 && len(c.RedisEndpoints) > 0 {
+		err = errors.New("either etcd endpoints or redis endpoints could be used as cache, not both")
+		return
+	}
+
+	if c.CacheMaxStaleness < 0 || c.CacheMaxStalenessAdmin < 0 || c.CacheMaxStalenessUser < 0 {
+		err = errors.New("cache max staleness could not be negative")
+		return
+	}
+
+	if c.Listen == "" && c.ListenSocket == "" {
+		err = errors.New("either listen address or listen socket is required")
+		return
+	}
+
+	if c.Listen != "" {
+		if _, port, parseErr := net.SplitHostPort(c.Listen); parseErr != nil || port == "" {
+			err = errors.New("listen address should be in host:port or :port format")
+			return
+		}
+	}
+
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		err = errors.New("both tls certificate file and tls key file are required")
+		return
+	}
+
+	if c.TLSClientCAFile != "" && c.TLSCertFile == "" {
+		err = errors.New("tls client ca file requires tls certificate and key files")
+		return
+	}
+
+	if c.ShutdownTimeout <= 0 {
+		err = errors.New("shutdown timeout should be positive")
+		return
+	}
+
+	if c.RateLimitClient < 0 || c.RateLimitClientBurst < 0 || c.RateLimitBackend < 0 {
+		err = errors.New("rate limits could not be negative")
+		return
+	}
+
+	if c.RateLimitClient > 0 && c.RateLimitClientBurst == 0 {
+		err = errors.New("rate limit client burst should be positive when client rate limit is set")
+		return
+	}
+
+	switch c.UserBackend {
+	case "", linux.UserBackendCommand, linux.UserBackendNative:
+	default:
+		err = errors.New("linux user backend should be one of command or native")
+		return
+	}
+
+	if c.UserUIDSpan > 0 || c.UserUIDBase > 0 {
+		// uid 4294967295 is reserved as invalid uid
+		if c.UserUIDBase == 0 || c.UserUIDSpan == 0 || c.UserUIDBase+c.UserUIDSpan-1 >= 4294967295 {
+			err = errors.New("uid base and uid span should be positive and the uid range should fit 32 bits")
+			return
+		}
+		if !contains([]string{linux.UIDCollisionProbe, linux.UIDCollisionAuto, linux.UIDCollisionSkip}, c.UserUIDCollision) {
+			err = errors.New("uid collision strategy should be one of probe, auto or skip")
+			return
+		}
+	}
+
+	if err = c.NameMapping().Validate(); err != nil {
+		return
+	}
+
+	switch c.RenamePolicy {
+	case "", linux.RenamePolicyRename, linux.RenamePolicyAlias, linux.RenamePolicyNone:
+	default:
+		err = errors.New("rename policy should be one of rename, alias or none")
+		return
+	}
+
+	if c.RenamePolicy != linux.RenamePolicyNone && c.RenamePolicy != "" && c.StateFile == "" {
+		err = errors.New("state file is required to track renames")
+		return
+	}
+
+	if c.LinuxProfile != "" && c.LinuxProfile != linux.ProfileAuto {
+		if !contains(linux.ProfileNames(), c.LinuxProfile) {
+			err = fmt.Errorf("linux profile should be one of %v or %v", linux.ProfileAuto, strings.Join(linux.ProfileNames(), ", "))
+			return
+		}
+	}
+
+	switch c.AuditOutput {
+	case "", "none", "stdout", "syslog":
+	case "file":
+		if c.AuditFile == "" {
+			err = errors.New("audit file is required when audit output is file")
+			return
+		}
+	default:
+		err = errors.New("audit output should be one of none, stdout, syslog or file")
+		return
+	}
+
+	if c.ListenSocket != "" {
+		if _, parseErr := strconv.ParseUint(c.ListenSocketMode, 8, 32); parseErr != nil {
+			err = errors.New("listen socket mode should be octal number like 0660")
+		}
 	}
 	return
 }
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// NameMapping - return rules mapping GitHub logins to linux user names, config should be validated before
+func (c Config) NameMapping() linux.Mapping {
+	return linux.NewMapping(c.UserNameOverrides, c.UserNameSubstitutions, c.UserNamePrefix, c.UserNameSuffix, c.UserNameMaxLength)
+}
+
+// StatePath - return path of state file of managed users under root directory, empty if state file is not set
+func (c Config) StatePath() string {
+	if c.StateFile == "" {
+		return ""
+	}
+	return filepath.Join(c.Root, c.StateFile)
+}
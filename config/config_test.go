@@ -0,0 +1,42 @@
+/*
+ * Github Authorized Keys - Use GitHub teams to manage system user accounts and authorized_keys
+ *
+ * Copyright 2016 Cloud Posse, LLC <hello@cloudposse.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Config", func() {
+	Describe("StatePath()", func() {
+		It("should resolve state file under root", func() {
+			cfg := Config{Root: "/host", StateFile: "/var/lib/github-authorized-keys/users.json"}
+			Expect(cfg.StatePath()).To(Equal("/host/var/lib/github-authorized-keys/users.json"))
+		})
+
+		It("should keep state file with default root", func() {
+			cfg := Config{Root: "/", StateFile: "/var/lib/github-authorized-keys/users.json"}
+			Expect(cfg.StatePath()).To(Equal("/var/lib/github-authorized-keys/users.json"))
+		})
+
+		It("should return empty path without state file", func() {
+			Expect(Config{Root: "/host"}.StatePath()).To(Equal(""))
+		})
+	})
+})
@@ -0,0 +1,96 @@
+/*
+ * Github Authorized Keys - Use GitHub teams to manage system user accounts and authorized_keys
+ *
+ * Copyright 2016 Cloud Posse, LLC <hello@cloudposse.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"reflect"
+	"sort"
+	"sync/atomic"
+)
+
+// Holder - keeps current config, so it could be swapped while server and scheduler are running
+type Holder struct {
+	value atomic.Value
+}
+
+// NewHolder - creates holder of {cfg}
+func NewHolder(cfg Config) *Holder {
+	holder := &Holder{}
+	holder.Store(cfg)
+	return holder
+}
+
+// Load - return current config
+func (h *Holder) Load() Config {
+	return h.value.Load().(Config)
+}
+
+// Store - replace current config with {cfg}
+func (h *Holder) Store(cfg Config) {
+	h.value.Store(cfg)
+}
+
+// RestartRequired - return names of options that differ between {c} and {other}, but are applied only on start
+// (listeners, TLS, cache, audit, rate limits, sync interval)
+func (c Config) RestartRequired(other Config) []string {
+	options := map[string][2]interface{}{
+		"Listen":                 {c.Listen, other.Listen},
+		"ListenSocket":           {c.ListenSocket, other.ListenSocket},
+		"ListenSocketOwner":      {c.ListenSocketOwner, other.ListenSocketOwner},
+		"ListenSocketGroup":      {c.ListenSocketGroup, other.ListenSocketGroup},
+		"ListenSocketMode":       {c.ListenSocketMode, other.ListenSocketMode},
+		"TLSCertFile":            {c.TLSCertFile, other.TLSCertFile},
+		"TLSKeyFile":             {c.TLSKeyFile, other.TLSKeyFile},
+		"TLSClientCAFile":        {c.TLSClientCAFile, other.TLSClientCAFile},
+		"EtcdEndpoints":          {c.EtcdEndpoints, other.EtcdEndpoints},
+		"EtcdPrefix":             {c.EtcdPrefix, other.EtcdPrefix},
+		"EtcdTTL":                {c.EtcdTTL, other.EtcdTTL},
+		"RedisEndpoints":         {c.RedisEndpoints, other.RedisEndpoints},
+		"RedisMasterName":        {c.RedisMasterName, other.RedisMasterName},
+		"RedisPassword":          {c.RedisPassword, other.RedisPassword},
+		"RedisPasswordFile":      {c.RedisPasswordFile, other.RedisPasswordFile},
+		"RedisDB":                {c.RedisDB, other.RedisDB},
+		"RedisTLS":               {c.RedisTLS, other.RedisTLS},
+		"RedisTLSCAFile":         {c.RedisTLSCAFile, other.RedisTLSCAFile},
+		"RedisPrefix":            {c.RedisPrefix, other.RedisPrefix},
+		"RedisTTL":               {c.RedisTTL, other.RedisTTL},
+		"CacheEncryptionKeyFile": {c.CacheEncryptionKeyFile, other.CacheEncryptionKeyFile},
+		"CacheSigningKeyFile":    {c.CacheSigningKeyFile, other.CacheSigningKeyFile},
+		"CacheVerifyKeyFile":     {c.CacheVerifyKeyFile, other.CacheVerifyKeyFile},
+		"AuditOutput":            {c.AuditOutput, other.AuditOutput},
+		"AuditFile":              {c.AuditFile, other.AuditFile},
+		"AuditFileMaxSize":       {c.AuditFileMaxSize, other.AuditFileMaxSize},
+		"AuditFileMaxBackups":    {c.AuditFileMaxBackups, other.AuditFileMaxBackups},
+		"AuditFileMaxAge":        {c.AuditFileMaxAge, other.AuditFileMaxAge},
+		"RateLimitClient":        {c.RateLimitClient, other.RateLimitClient},
+		"RateLimitClientBurst":   {c.RateLimitClientBurst, other.RateLimitClientBurst},
+		"RateLimitBackend":       {c.RateLimitBackend, other.RateLimitBackend},
+		"Interval":               {c.Interval, other.Interval},
+		"IntegrateWithSSH":       {c.IntegrateWithSSH, other.IntegrateWithSSH},
+	}
+
+	changed := []string{}
+	for name, values := range options {
+		if !reflect.DeepEqual(values[0], values[1]) {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
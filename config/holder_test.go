@@ -0,0 +1,59 @@
+/*
+ * Github Authorized Keys - Use GitHub teams to manage system user accounts and authorized_keys
+ *
+ * Copyright 2016 Cloud Posse, LLC <hello@cloudposse.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Holder", func() {
+	It("should return stored config", func() {
+		holder := NewHolder(Config{GithubOrganization: "cloudposse"})
+		Expect(holder.Load().GithubOrganization).To(Equal("cloudposse"))
+
+		holder.Store(Config{GithubOrganization: "terjekv"})
+		Expect(holder.Load().GithubOrganization).To(Equal("terjekv"))
+	})
+
+	It("should keep config loaded before store", func() {
+		holder := NewHolder(Config{Templates: map[string][]string{"linux_user_del_tpl": {"deluser", "{username}"}}})
+		cfg := holder.Load()
+
+		holder.Store(Config{Templates: map[string][]string{"linux_user_del_tpl": {"userdel", "{username}"}}})
+		Expect(cfg.Templates["linux_user_del_tpl"]).To(Equal([]string{"deluser", "{username}"}))
+	})
+
+	Describe("RestartRequired()", func() {
+		It("should return changed options applied on start only", func() {
+			cfg := Config{Listen: ":301", EtcdEndpoints: []string{"etcd-1:2379"}, Interval: 60}
+			other := Config{Listen: ":302", EtcdEndpoints: []string{"etcd-2:2379"}, Interval: 60}
+			Expect(cfg.RestartRequired(other)).To(Equal([]string{"EtcdEndpoints", "Listen"}))
+		})
+
+		It("should ignore options applied on reload", func() {
+			cfg := Config{GithubOrganization: "cloudposse", ShutdownTimeout: time.Second, UserShell: "/bin/bash"}
+			other := Config{GithubOrganization: "terjekv", ShutdownTimeout: time.Minute, UserShell: "/bin/sh",
+				Templates: map[string][]string{"linux_user_del_tpl": {"userdel", "{username}"}}}
+			Expect(cfg.RestartRequired(other)).To(BeEmpty())
+		})
+	})
+})
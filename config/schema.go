@@ -0,0 +1,372 @@
+/*
+ * Github Authorized Keys - Use GitHub teams to manage system user accounts and authorized_keys
+ *
+ * Copyright 2016 Cloud Posse, LLC <hello@cloudposse.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"bytes"
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SchemaVersion - version of nested config file schema.
+// Files without version use flat keys named as command line options.
+const SchemaVersion = 2
+
+// commandTemplate - command given as string split on whitespace, or as list of arguments
+type commandTemplate struct {
+	value interface{}
+}
+
+// UnmarshalYAML - accept string or list of strings
+func (t *commandTemplate) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		var value string
+		if err := node.Decode(&value); err != nil {
+			return err
+		}
+		t.value = value
+	case yaml.SequenceNode:
+		var value []string
+		if err := node.Decode(&value); err != nil {
+			return err
+		}
+		t.value = value
+	default:
+		return fmt.Errorf("line %v: command template should be string or list of arguments", node.Line)
+	}
+	return nil
+}
+
+type teamSection struct {
+	Name   *string  `yaml:"name"`
+	ID     *int     `yaml:"id"`
+	Groups []string `yaml:"groups"`
+}
+
+type tlsClientSection struct {
+	CAFile     *string `yaml:"ca_file"`
+	CertFile   *string `yaml:"cert_file"`
+	KeyFile    *string `yaml:"key_file"`
+	ServerName *string `yaml:"server_name"`
+}
+
+type githubSection struct {
+	APIToken       *string `yaml:"api_token"`
+	APITokenFile   *string `yaml:"api_token_file"`
+	Organization   *string `yaml:"organization"`
+	APIMaxPageSize *int    `yaml:"api_max_page_size"`
+}
+
+type teamsSection struct {
+	Admin teamSection `yaml:"admin"`
+	User  teamSection `yaml:"user"`
+}
+
+type uidSection struct {
+	Base      *int64  `yaml:"base"`
+	Span      *int64  `yaml:"span"`
+	Collision *string `yaml:"collision"`
+}
+
+type linuxSection struct {
+	Root              *string          `yaml:"root"`
+	Shell             *string          `yaml:"shell"`
+	SyncInterval      *int64           `yaml:"sync_interval"`
+	UserBackend       *string          `yaml:"user_backend"`
+	Gecos             *bool            `yaml:"gecos"`
+	Profile           *string          `yaml:"profile"`
+	UID               uidSection       `yaml:"uid"`
+	Username          usernameSection  `yaml:"username"`
+	StateFile         *string          `yaml:"state_file"`
+	RenamePolicy      *string          `yaml:"rename_policy"`
+	UserAddTpl        *commandTemplate `yaml:"user_add_tpl"`
+	UserAddWithGIDTpl *commandTemplate `yaml:"user_add_with_gid_tpl"`
+	UserAddWithUIDTpl *commandTemplate `yaml:"user_add_with_uid_tpl"`
+	UserAddToGroupTpl *commandTemplate `yaml:"user_add_to_group_tpl"`
+	GroupAddTpl       *commandTemplate `yaml:"group_add_tpl"`
+	GroupDelTpl       *commandTemplate `yaml:"group_del_tpl"`
+	UserDelTpl        *commandTemplate `yaml:"user_del_tpl"`
+	UserRenameTpl     *commandTemplate `yaml:"user_rename_tpl"`
+	GroupRenameTpl    *commandTemplate `yaml:"group_rename_tpl"`
+	UserSetGecosTpl   *commandTemplate `yaml:"user_set_gecos_tpl"`
+}
+
+type usernameSection struct {
+	Overrides map[string]string `yaml:"overrides"`
+	// Substitutions - list, so they are applied in the given order
+	Substitutions []substitution `yaml:"substitutions"`
+	Prefix        *string        `yaml:"prefix"`
+	Suffix        *string        `yaml:"suffix"`
+	MaxLength     *int           `yaml:"max_length"`
+}
+
+type substitution struct {
+	From string `yaml:"from"`
+	To   string `yaml:"to"`
+}
+
+type etcdSection struct {
+	Endpoints []string `yaml:"endpoints"`
+	Prefix    *string  `yaml:"prefix"`
+	TTL       *int64   `yaml:"ttl"`
+}
+
+type redisSection struct {
+	Endpoints    []string `yaml:"endpoints"`
+	MasterName   *string  `yaml:"master_name"`
+	Password     *string  `yaml:"password"`
+	PasswordFile *string  `yaml:"password_file"`
+	DB           *int     `yaml:"db"`
+	TLS          *bool    `yaml:"tls"`
+	TLSCAFile    *string  `yaml:"tls_ca_file"`
+	Prefix       *string  `yaml:"prefix"`
+	TTL          *int64   `yaml:"ttl"`
+}
+
+type cacheSection struct {
+	MaxStaleness      *int64       `yaml:"max_staleness"`
+	MaxStalenessAdmin *int64       `yaml:"max_staleness_admin"`
+	MaxStalenessUser  *int64       `yaml:"max_staleness_user"`
+	EncryptionKeyFile *string      `yaml:"encryption_key_file"`
+	SigningKeyFile    *string      `yaml:"signing_key_file"`
+	VerifyKeyFile     *string      `yaml:"verify_key_file"`
+	Etcd              etcdSection  `yaml:"etcd"`
+	Redis             redisSection `yaml:"redis"`
+}
+
+type socketSection struct {
+	Path  *string `yaml:"path"`
+	Owner *string `yaml:"owner"`
+	Group *string `yaml:"group"`
+	Mode  *string `yaml:"mode"`
+}
+
+type tlsSection struct {
+	CertFile     *string `yaml:"cert_file"`
+	KeyFile      *string `yaml:"key_file"`
+	ClientCAFile *string `yaml:"client_ca_file"`
+}
+
+type auditSection struct {
+	Output     *string `yaml:"output"`
+	File       *string `yaml:"file"`
+	MaxSize    *int    `yaml:"max_size"`
+	MaxBackups *int    `yaml:"max_backups"`
+	MaxAge     *int    `yaml:"max_age"`
+}
+
+type rateLimitSection struct {
+	Client      *int `yaml:"client"`
+	ClientBurst *int `yaml:"client_burst"`
+	Backend     *int `yaml:"backend"`
+}
+
+type serverSection struct {
+	Listen          *string          `yaml:"listen"`
+	ShutdownTimeout *int64           `yaml:"shutdown_timeout"`
+	Socket          socketSection    `yaml:"socket"`
+	TLS             tlsSection       `yaml:"tls"`
+	Audit           auditSection     `yaml:"audit"`
+	RateLimit       rateLimitSection `yaml:"rate_limit"`
+	UsersAPITCP     *bool            `yaml:"users_api_tcp"`
+}
+
+type sshSection struct {
+	Integrate                *bool            `yaml:"integrate"`
+	RestartTpl               *commandTemplate `yaml:"restart_tpl"`
+	AuthorizedKeysCommandTpl *string          `yaml:"authorized_keys_command_tpl"`
+}
+
+type lookupSection struct {
+	URL     *string          `yaml:"url"`
+	Socket  *string          `yaml:"socket"`
+	Timeout *int64           `yaml:"timeout"`
+	TLS     tlsClientSection `yaml:"tls"`
+}
+
+// File - nested config file schema of version 2
+type File struct {
+	Version int           `yaml:"version"`
+	Github  githubSection `yaml:"github"`
+	Teams   teamsSection  `yaml:"teams"`
+	Linux   linuxSection  `yaml:"linux"`
+	Cache   cacheSection  `yaml:"cache"`
+	Server  serverSection `yaml:"server"`
+	SSH     sshSection    `yaml:"ssh"`
+	Lookup  lookupSection `yaml:"lookup"`
+}
+
+// ParseFile - strictly decode nested config file {content}, unknown keys are errors.
+// Returns values set in file keyed by flat option names, so they could be merged under flags and environment variables.
+func ParseFile(content []byte) (map[string]interface{}, error) {
+	file := File{}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("config file: %v", err)
+	}
+
+	if file.Version != SchemaVersion {
+		return nil, fmt.Errorf("config file: unsupported version %v, expected %v", file.Version, SchemaVersion)
+	}
+
+	return file.options(), nil
+}
+
+// options - flatten values set in file into options map
+func (f *File) options() map[string]interface{} {
+	options := map[string]interface{}{}
+
+	set := func(option string, value interface{}) {
+		switch typed := value.(type) {
+		case *string:
+			if typed != nil {
+				options[option] = *typed
+			}
+		case *int:
+			if typed != nil {
+				options[option] = *typed
+			}
+		case *int64:
+			if typed != nil {
+				options[option] = *typed
+			}
+		case *bool:
+			if typed != nil {
+				options[option] = *typed
+			}
+		case *commandTemplate:
+			if typed != nil {
+				options[option] = typed.value
+			}
+		case []string:
+			// Lists and maps are kept structured, so values could contain separators of flat options
+			if typed != nil {
+				options[option] = typed
+			}
+		case map[string]string:
+			if typed != nil {
+				options[option] = typed
+			}
+		case []substitution:
+			// Substitutions keep their order as list of from/to maps
+			if typed != nil {
+				pairs := []map[string]string{}
+				for _, substitution := range typed {
+					pairs = append(pairs, map[string]string{"from": substitution.From, "to": substitution.To})
+				}
+				options[option] = pairs
+			}
+		}
+	}
+
+	set("github_api_token", f.Github.APIToken)
+	set("github_api_token_file", f.Github.APITokenFile)
+	set("github_organization", f.Github.Organization)
+	set("github_api_max_page_size", f.Github.APIMaxPageSize)
+
+	set("github_admin_team_name", f.Teams.Admin.Name)
+	set("github_admin_team_id", f.Teams.Admin.ID)
+	set("sync_users_admin_groups", f.Teams.Admin.Groups)
+	set("github_user_team_name", f.Teams.User.Name)
+	set("github_user_team_id", f.Teams.User.ID)
+	set("sync_users_users_groups", f.Teams.User.Groups)
+
+	set("sync_users_root", f.Linux.Root)
+	set("sync_users_shell", f.Linux.Shell)
+	set("sync_users_interval", f.Linux.SyncInterval)
+	set("linux_user_backend", f.Linux.UserBackend)
+	set("sync_users_gecos", f.Linux.Gecos)
+	set("linux_profile", f.Linux.Profile)
+	set("sync_users_uid_base", f.Linux.UID.Base)
+	set("sync_users_uid_span", f.Linux.UID.Span)
+	set("sync_users_uid_collision", f.Linux.UID.Collision)
+	set("sync_users_name_overrides", f.Linux.Username.Overrides)
+	set("sync_users_name_substitutions", f.Linux.Username.Substitutions)
+	set("sync_users_name_prefix", f.Linux.Username.Prefix)
+	set("sync_users_name_suffix", f.Linux.Username.Suffix)
+	set("sync_users_name_max_length", f.Linux.Username.MaxLength)
+	set("sync_users_state_file", f.Linux.StateFile)
+	set("sync_users_rename_policy", f.Linux.RenamePolicy)
+	set("linux_user_add_tpl", f.Linux.UserAddTpl)
+	set("linux_user_add_with_gid_tpl", f.Linux.UserAddWithGIDTpl)
+	set("linux_user_add_with_uid_tpl", f.Linux.UserAddWithUIDTpl)
+	set("linux_user_add_to_group_tpl", f.Linux.UserAddToGroupTpl)
+	set("linux_group_add_tpl", f.Linux.GroupAddTpl)
+	set("linux_group_del_tpl", f.Linux.GroupDelTpl)
+	set("linux_user_del_tpl", f.Linux.UserDelTpl)
+	set("linux_user_rename_tpl", f.Linux.UserRenameTpl)
+	set("linux_group_rename_tpl", f.Linux.GroupRenameTpl)
+	set("linux_user_set_gecos_tpl", f.Linux.UserSetGecosTpl)
+
+	set("cache_max_staleness", f.Cache.MaxStaleness)
+	set("cache_max_staleness_admin", f.Cache.MaxStalenessAdmin)
+	set("cache_max_staleness_user", f.Cache.MaxStalenessUser)
+	set("cache_encryption_key_file", f.Cache.EncryptionKeyFile)
+	set("cache_signing_key_file", f.Cache.SigningKeyFile)
+	set("cache_verify_key_file", f.Cache.VerifyKeyFile)
+	set("etcd_endpoint", f.Cache.Etcd.Endpoints)
+	set("etcd_prefix", f.Cache.Etcd.Prefix)
+	set("etcd_ttl", f.Cache.Etcd.TTL)
+	set("redis_endpoint", f.Cache.Redis.Endpoints)
+	set("redis_master_name", f.Cache.Redis.MasterName)
+	set("redis_password", f.Cache.Redis.Password)
+	set("redis_password_file", f.Cache.Redis.PasswordFile)
+	set("redis_db", f.Cache.Redis.DB)
+	set("redis_tls", f.Cache.Redis.TLS)
+	set("redis_tls_ca_file", f.Cache.Redis.TLSCAFile)
+	set("redis_prefix", f.Cache.Redis.Prefix)
+	set("redis_ttl", f.Cache.Redis.TTL)
+
+	set("listen", f.Server.Listen)
+	set("shutdown_timeout", f.Server.ShutdownTimeout)
+	set("listen_socket", f.Server.Socket.Path)
+	set("listen_socket_owner", f.Server.Socket.Owner)
+	set("listen_socket_group", f.Server.Socket.Group)
+	set("listen_socket_mode", f.Server.Socket.Mode)
+	set("tls_cert_file", f.Server.TLS.CertFile)
+	set("tls_key_file", f.Server.TLS.KeyFile)
+	set("tls_client_ca_file", f.Server.TLS.ClientCAFile)
+	set("audit_output", f.Server.Audit.Output)
+	set("audit_file", f.Server.Audit.File)
+	set("audit_file_max_size", f.Server.Audit.MaxSize)
+	set("audit_file_max_backups", f.Server.Audit.MaxBackups)
+	set("audit_file_max_age", f.Server.Audit.MaxAge)
+	set("rate_limit_client", f.Server.RateLimit.Client)
+	set("rate_limit_client_burst", f.Server.RateLimit.ClientBurst)
+	set("rate_limit_backend", f.Server.RateLimit.Backend)
+	set("users_api_tcp", f.Server.UsersAPITCP)
+
+	set("integrate_ssh", f.SSH.Integrate)
+	set("ssh_restart_tpl", f.SSH.RestartTpl)
+	set("authorized_keys_command_tpl", f.SSH.AuthorizedKeysCommandTpl)
+
+	set("lookup_url", f.Lookup.URL)
+	set("lookup_socket", f.Lookup.Socket)
+	set("lookup_timeout", f.Lookup.Timeout)
+	set("lookup_tls_ca_file", f.Lookup.TLS.CAFile)
+	set("lookup_tls_cert_file", f.Lookup.TLS.CertFile)
+	set("lookup_tls_key_file", f.Lookup.TLS.KeyFile)
+	set("lookup_tls_server_name", f.Lookup.TLS.ServerName)
+
+	return options
+}
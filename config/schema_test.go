@@ -0,0 +1,100 @@
+/*
+ * Github Authorized Keys - Use GitHub teams to manage system user accounts and authorized_keys
+ *
+ * Copyright 2016 Cloud Posse, LLC <hello@cloudposse.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ParseFile()", func() {
+	It("should map nested keys to flat options", func() {
+		options, err := ParseFile([]byte(`
+version: 2
+github:
+  organization: cloudposse
+teams:
+  admin:
+    name: ssh
+    groups: [wheel, adm]
+linux:
+  uid:
+    span: 1000
+  user_add_tpl: [useradd, "{username}"]
+server:
+  rate_limit:
+    client: 60
+`))
+		Expect(err).To(BeNil())
+		Expect(options).To(Equal(map[string]interface{}{
+			"github_organization":     "cloudposse",
+			"github_admin_team_name":  "ssh",
+			"sync_users_admin_groups": []string{"wheel", "adm"},
+			"sync_users_uid_span":     int64(1000),
+			"linux_user_add_tpl":      []string{"useradd", "{username}"},
+			"rate_limit_client":       60,
+		}))
+	})
+
+	It("should keep structured user name rules in order", func() {
+		options, err := ParseFile([]byte(`
+version: 2
+linux:
+  username:
+    overrides:
+      john-doe: jdoe
+      alice: al
+    substitutions:
+      - from: "."
+        to: "-"
+      - from: "="
+        to: ","
+`))
+		Expect(err).To(BeNil())
+		Expect(options).To(Equal(map[string]interface{}{
+			"sync_users_name_overrides":     map[string]string{"alice": "al", "john-doe": "jdoe"},
+			"sync_users_name_substitutions": []map[string]string{{"from": ".", "to": "-"}, {"from": "=", "to": ","}},
+		}))
+	})
+
+	It("should reject unknown top level key", func() {
+		_, err := ParseFile([]byte("version: 2\nbogus: true\n"))
+		Expect(err).To(MatchError(ContainSubstring("field bogus not found")))
+	})
+
+	It("should reject unknown nested key", func() {
+		_, err := ParseFile([]byte("version: 2\ngithub:\n  api_tokne: secret\n"))
+		Expect(err).To(MatchError(ContainSubstring("field api_tokne not found")))
+	})
+
+	It("should reject flat option in nested file", func() {
+		_, err := ParseFile([]byte("version: 2\ngithub_organization: cloudposse\n"))
+		Expect(err).NotTo(BeNil())
+	})
+
+	It("should reject unsupported version", func() {
+		_, err := ParseFile([]byte("version: 1\n"))
+		Expect(err).To(MatchError(ContainSubstring("unsupported version 1")))
+	})
+
+	It("should reject command template of wrong type", func() {
+		_, err := ParseFile([]byte("version: 2\nlinux:\n  user_add_tpl:\n    command: useradd\n"))
+		Expect(err).To(MatchError(ContainSubstring("command template should be string or list of arguments")))
+	})
+})
@@ -0,0 +1,164 @@
+/*
+ * Github Authorized Keys - Use GitHub teams to manage system user accounts and authorized_keys
+ *
+ * Copyright 2016 Cloud Posse, LLC <hello@cloudposse.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// CredentialsDirectoryEnv - environment variable set by systemd for units with LoadCredential=
+const CredentialsDirectoryEnv = "CREDENTIALS_DIRECTORY"
+
+const (
+	secretEnvPrefix        = "env:"
+	secretFilePrefix       = "file:"
+	secretCredentialPrefix = "credential:"
+)
+
+// secretFile - content of secret file, re-read when file modification time or size changes
+type secretFile struct {
+	modTime time.Time
+	size    int64
+	value   string
+}
+
+var (
+	secretFilesMutex sync.Mutex
+	secretFiles      = map[string]secretFile{}
+)
+
+// CredentialPath - return path of systemd credential {name}, or empty string if there is no such credential
+func CredentialPath(name string) string {
+	dir := os.Getenv(CredentialsDirectoryEnv)
+	if dir == "" {
+		return ""
+	}
+
+	path := filepath.Join(dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
+// ResolveSecret - resolve secret {value} given as reference
+// env:NAME - value of environment variable NAME,
+// file:/path - content of file,
+// credential:NAME - content of systemd credential NAME,
+// any other value is used as is.
+func ResolveSecret(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, secretEnvPrefix):
+		name := strings.TrimPrefix(value, secretEnvPrefix)
+		secret, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %v is not set", name)
+		}
+		return secret, nil
+
+	case strings.HasPrefix(value, secretFilePrefix), strings.HasPrefix(value, secretCredentialPrefix):
+		path, err := ResolveSecretPath(value)
+		if err != nil {
+			return "", err
+		}
+		return ReadSecretFile(path)
+	}
+	return value, nil
+}
+
+// ResolveSecretPath - resolve path of secret file given as reference
+// credential:NAME - path of systemd credential NAME,
+// env:NAME - path from environment variable NAME,
+// file:/path or any other value is used as path.
+func ResolveSecretPath(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, secretCredentialPrefix):
+		name := strings.TrimPrefix(value, secretCredentialPrefix)
+		if os.Getenv(CredentialsDirectoryEnv) == "" {
+			return "", fmt.Errorf("credential %v is requested, but %v is not set", name, CredentialsDirectoryEnv)
+		}
+		return filepath.Join(os.Getenv(CredentialsDirectoryEnv), name), nil
+
+	case strings.HasPrefix(value, secretEnvPrefix):
+		return ResolveSecret(value)
+
+	case strings.HasPrefix(value, secretFilePrefix):
+		return strings.TrimPrefix(value, secretFilePrefix), nil
+	}
+	return value, nil
+}
+
+// ReadSecretFile - return content of secret file {path} without trailing new lines.
+// Content is cached and read again only after the file is rotated.
+func ReadSecretFile(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	secretFilesMutex.Lock()
+	defer secretFilesMutex.Unlock()
+
+	cached, ok := secretFiles[path]
+	if ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size() {
+		return cached.value, nil
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	value := strings.TrimRight(string(content), "\r\n")
+	if value == "" {
+		return "", fmt.Errorf("secret file %v is empty", path)
+	}
+
+	if ok && cached.value != value {
+		log.WithFields(log.Fields{"class": "config", "method": "ReadSecretFile"}).
+			Infof("Secret file %v is rotated", path)
+	}
+
+	secretFiles[path] = secretFile{modTime: info.ModTime(), size: info.Size(), value: value}
+	return value, nil
+}
+
+// GithubToken - return current GitHub API token.
+// Token file is read on every call, so rotated token is used without restart.
+func (c Config) GithubToken() string {
+	if c.GithubAPITokenFile == "" {
+		return c.GithubAPIToken
+	}
+
+	token, err := ReadSecretFile(c.GithubAPITokenFile)
+	if err != nil {
+		log.WithFields(log.Fields{"class": "config", "method": "GithubToken"}).
+			Errorf("Unable to read GitHub API token file, use token loaded before: %v", err)
+		return c.GithubAPIToken
+	}
+	return token
+}
@@ -0,0 +1,144 @@
+/*
+ * Github Authorized Keys - Use GitHub teams to manage system user accounts and authorized_keys
+ *
+ * Copyright 2016 Cloud Posse, LLC <hello@cloudposse.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Secrets", func() {
+	var dir string
+
+	writeSecret := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		Expect(ioutil.WriteFile(path, []byte(content), 0600)).To(BeNil())
+		return path
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "gak-secrets")
+		Expect(err).To(BeNil())
+		os.Setenv(CredentialsDirectoryEnv, dir)
+		os.Setenv("GAK_TEST_SECRET", "from-env")
+	})
+
+	AfterEach(func() {
+		os.Unsetenv(CredentialsDirectoryEnv)
+		os.Unsetenv("GAK_TEST_SECRET")
+		os.RemoveAll(dir)
+	})
+
+	Describe("ResolveSecret()", func() {
+		It("should use plain value as is", func() {
+			Expect(ResolveSecret("plain")).To(Equal("plain"))
+		})
+
+		It("should read environment variable", func() {
+			Expect(ResolveSecret("env:GAK_TEST_SECRET")).To(Equal("from-env"))
+		})
+
+		It("should fail on unset environment variable", func() {
+			_, err := ResolveSecret("env:GAK_TEST_MISSING")
+			Expect(err).To(MatchError(ContainSubstring("GAK_TEST_MISSING is not set")))
+		})
+
+		It("should read file without trailing new line", func() {
+			path := writeSecret("token", "from-file\n")
+			Expect(ResolveSecret("file:" + path)).To(Equal("from-file"))
+		})
+
+		It("should read systemd credential", func() {
+			writeSecret("github_api_token", "from-credential\r\n")
+			Expect(ResolveSecret("credential:github_api_token")).To(Equal("from-credential"))
+		})
+
+		It("should fail on credential without credentials directory", func() {
+			os.Unsetenv(CredentialsDirectoryEnv)
+			_, err := ResolveSecret("credential:github_api_token")
+			Expect(err).To(MatchError(ContainSubstring(CredentialsDirectoryEnv + " is not set")))
+		})
+
+		It("should fail on empty file", func() {
+			path := writeSecret("empty", "\n")
+			_, err := ResolveSecret("file:" + path)
+			Expect(err).To(MatchError(ContainSubstring("is empty")))
+		})
+	})
+
+	Describe("ResolveSecretPath()", func() {
+		It("should resolve references to paths", func() {
+			Expect(ResolveSecretPath("/etc/key")).To(Equal("/etc/key"))
+			Expect(ResolveSecretPath("file:/etc/key")).To(Equal("/etc/key"))
+			Expect(ResolveSecretPath("env:GAK_TEST_SECRET")).To(Equal("from-env"))
+			Expect(ResolveSecretPath("credential:cache.key")).To(Equal(filepath.Join(dir, "cache.key")))
+		})
+	})
+
+	Describe("CredentialPath()", func() {
+		It("should return path only for existing credential", func() {
+			writeSecret("redis_password", "secret")
+			Expect(CredentialPath("redis_password")).To(Equal(filepath.Join(dir, "redis_password")))
+			Expect(CredentialPath("github_api_token")).To(BeEmpty())
+		})
+	})
+
+	Describe("ReadSecretFile()", func() {
+		It("should cache content until modification time or size changes", func() {
+			path := writeSecret("token", "first")
+			modTime := time.Now().Add(-time.Hour).Truncate(time.Second)
+			Expect(os.Chtimes(path, modTime, modTime)).To(BeNil())
+			Expect(ReadSecretFile(path)).To(Equal("first"))
+
+			// Same size and modification time, cached content is returned
+			writeSecret("token", "other")
+			Expect(os.Chtimes(path, modTime, modTime)).To(BeNil())
+			Expect(ReadSecretFile(path)).To(Equal("first"))
+
+			// Rotated file is read again
+			Expect(os.Chtimes(path, modTime.Add(time.Minute), modTime.Add(time.Minute))).To(BeNil())
+			Expect(ReadSecretFile(path)).To(Equal("other"))
+
+			writeSecret("token", "rotated-token")
+			Expect(ReadSecretFile(path)).To(Equal("rotated-token"))
+		})
+	})
+
+	Describe("GithubToken()", func() {
+		It("should return rotated token from file", func() {
+			path := writeSecret("token", "first-token")
+			cfg := Config{GithubAPIToken: "first-token", GithubAPITokenFile: path}
+			Expect(cfg.GithubToken()).To(Equal("first-token"))
+
+			writeSecret("token", "second-token-rotated")
+			Expect(cfg.GithubToken()).To(Equal("second-token-rotated"))
+		})
+
+		It("should fall back to loaded token when file is unreadable", func() {
+			cfg := Config{GithubAPIToken: "loaded", GithubAPITokenFile: filepath.Join(dir, "missing")}
+			Expect(cfg.GithubToken()).To(Equal("loaded"))
+		})
+	})
+})
@@ -0,0 +1,86 @@
+/*
+ * Github Authorized Keys - Use GitHub teams to manage system user accounts and authorized_keys
+ *
+ * Copyright 2016 Cloud Posse, LLC <hello@cloudposse.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/terjekv/github-authorized-keys/model/linux"
+)
+
+// ValidateSystem - check that config matches the host: root directory, shell and groups exist and configured files are readable.
+// These checks depend on the host state, so they are reported separately from Validate.
+// Groups are looked up with {groupExists}, so they are checked by the same users backend that sync uses.
+func (c Config) ValidateSystem(groupExists func(group string) bool) error {
+	errs := validation.Errors{}
+
+	if info, err := os.Stat(c.Root); err != nil || !info.IsDir() {
+		errs["Root"] = fmt.Errorf("directory %v does not exist", c.Root)
+		return errs
+	}
+
+	if info, err := os.Stat(filepath.Join(c.Root, c.UserShell)); err != nil || info.IsDir() || info.Mode()&0111 == 0 {
+		errs["UserShell"] = fmt.Errorf("%v is not an executable file", c.UserShell)
+	}
+
+	if c.UserBackend == linux.UserBackendNative {
+		for _, database := range []string{"/etc/passwd", "/etc/group"} {
+			if _, err := os.Stat(filepath.Join(c.Root, database)); err != nil {
+				errs["UserBackend"] = fmt.Errorf("%v does not exist", filepath.Join(c.Root, database))
+			}
+		}
+	}
+
+	for option, groups := range map[string][]string{"UserAdminGroups": c.UserAdminGroups, "UserUserGroups": c.UserUserGroups} {
+		for _, group := range groups {
+			if !groupExists(group) {
+				errs[option] = fmt.Errorf("group %v does not exist", group)
+			}
+		}
+	}
+
+	files := map[string]string{
+		"TLSCertFile":            c.TLSCertFile,
+		"TLSKeyFile":             c.TLSKeyFile,
+		"TLSClientCAFile":        c.TLSClientCAFile,
+		"RedisTLSCAFile":         c.RedisTLSCAFile,
+		"CacheEncryptionKeyFile": c.CacheEncryptionKeyFile,
+		"CacheSigningKeyFile":    c.CacheSigningKeyFile,
+		"CacheVerifyKeyFile":     c.CacheVerifyKeyFile,
+	}
+	for option, path := range files {
+		if path == "" {
+			continue
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			errs[option] = fmt.Errorf("%v is not readable", path)
+			continue
+		}
+		file.Close()
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
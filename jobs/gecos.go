@@ -0,0 +1,36 @@
+package jobs
+
+import (
+	log "github.com/sirupsen/logrus"
+	"github.com/terjekv/github-authorized-keys/api"
+	"github.com/terjekv/github-authorized-keys/config"
+)
+
+// profileGecos - return comment field built from GitHub profile of {login}, empty when disabled or profile is unavailable
+func profileGecos(cfg config.Config, c *api.GithubClient, login string) string {
+	if !cfg.UserGecos {
+		return ""
+	}
+
+	profile, err := c.GetUser(login)
+	if err != nil {
+		log.WithFields(log.Fields{"subsystem": "jobs", "job": "profileGecos", "user": login}).
+			Warnf("Unable to get GitHub profile, comment field is not updated: %v", err)
+		return ""
+	}
+	return api.Gecos(profile.GetName(), login, profile.GetEmail())
+}
+
+// updateGecos - set comment field of existing user {name} when GitHub profile changed
+func updateGecos(linux api.Linux, name, gecos string) {
+	if gecos == "" || linux.UserGecos(name) == gecos {
+		return
+	}
+
+	logger := log.WithFields(log.Fields{"subsystem": "jobs", "job": "updateGecos", "user": name})
+	if err := linux.UserSetGecos(name, gecos); err != nil {
+		logger.Errorf("Unable to update comment field: %v", err)
+		return
+	}
+	logger.Infof("Comment field updated to %q", gecos)
+}
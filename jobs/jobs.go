@@ -1,13 +1,17 @@
 package jobs
 
 import (
+	"context"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/google/go-github/v43/github"
 	"github.com/goruha/permbits"
 	"github.com/jasonlvhit/gocron"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
+	"github.com/terjekv/github-authorized-keys/accounts"
 	"github.com/terjekv/github-authorized-keys/api"
 	"github.com/terjekv/github-authorized-keys/config"
 	model "github.com/terjekv/github-authorized-keys/model/linux"
@@ -18,13 +22,24 @@ const wrapperScriptTpl = `#!/bin/bash
 curl http://localhost:{port}/user/$1/authorized_keys
 `
 
+const socketWrapperScriptTpl = `#!/bin/bash
+curl --unix-socket {socket} http://localhost/user/$1/authorized_keys
+`
+
 func init() {
-	viper.SetDefault("ssh_restart_tpl", "/usr/sbin/service ssh force-reload")
 	viper.SetDefault("authorized_keys_command_tpl", "/usr/bin/github-authorized-keys")
 }
 
-// Run - start scheduled jobs
-func Run(cfg config.Config) {
+// syncMutex - held while users are synchronized, so shutdown could wait for sync in progress
+var syncMutex sync.Mutex
+
+// schedulerStop - stops jobs scheduler, nil when scheduler is not started
+var schedulerStop chan bool
+
+// Run - start scheduled jobs, each sync uses config current at the time it starts
+func Run(holder *config.Holder) {
+	cfg := holder.Load()
+
 	log.Info("Run syncUsers job on start")
 	syncUsers(cfg)
 
@@ -34,43 +49,97 @@ func Run(cfg config.Config) {
 	}
 
 	if cfg.Interval != 0 {
-		gocron.Every(cfg.Interval).Seconds().Do(syncUsers, cfg)
+		gocron.Every(cfg.Interval).Seconds().Do(func() { syncUsers(holder.Load()) })
 
 		// function Start start all the pending jobs
-		gocron.Start()
+		schedulerStop = gocron.Start()
 		log.Info("Start jobs scheduler")
 	}
 }
 
+// Stop - stop jobs scheduler and wait until sync in progress finishes or {ctx} is done
+func Stop(ctx context.Context) error {
+	if schedulerStop != nil {
+		schedulerStop <- true
+		gocron.Clear()
+		schedulerStop = nil
+		log.Info("Stop jobs scheduler")
+	}
+
+	finished := make(chan struct{})
+	go func() {
+		// Sync in progress releases the mutex once it is done, scheduler does not start new ones
+		syncMutex.Lock()
+		syncMutex.Unlock()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 func syncUsers(cfg config.Config) {
 	logger := log.WithFields(log.Fields{"subsystem": "jobs", "job": "syncUsers"})
 
-	c := api.NewGithubClient(cfg.GithubAPIToken, cfg.GithubOrganization)
+	syncMutex.Lock()
+	defer syncMutex.Unlock()
+
+	startedAt := time.Now()
+	managedUsers := map[string]int{}
+	var syncErr error
+
+	defer func() {
+		recordSync(startedAt, managedUsers, syncErr)
+	}()
+
+	state := loadState(cfg)
+	if state != nil {
+		defer func() {
+			if err := state.Save(); err != nil {
+				logger.Errorf("Unable to save state of managed users: %v", err)
+			}
+		}()
+	}
+
+	c := api.NewGithubClient(cfg.GithubToken(), cfg.GithubOrganization)
+	c.SetPageSize(cfg.GithubAPIPageSize)
 
 	if cfg.GithubAdminTeamName != "" {
 		team, err := c.GetTeam(cfg.GithubAdminTeamName, cfg.GithubAdminTeamID)
 		if err != nil {
 			logger.Error(err)
+			syncErr = err
 			return
 		}
 
-		syncTeamUsers(cfg, c, team, cfg.UserAdminGroups)
+		if managedUsers[roleAdmin], syncErr = syncTeamUsers(cfg, c, state, team, cfg.UserAdminGroups); syncErr != nil {
+			return
+		}
 	}
 
 	if cfg.GithubUserTeamName != "" {
 		team, err := c.GetTeam(cfg.GithubUserTeamName, cfg.GithubUserTeamID)
 		if err != nil {
 			logger.Error(err)
+			syncErr = err
 			return
 		}
 
-		syncTeamUsers(cfg, c, team, cfg.UserUserGroups)
+		if managedUsers[roleUser], syncErr = syncTeamUsers(cfg, c, state, team, cfg.UserUserGroups); syncErr != nil {
+			return
+		}
 	}
 }
 
-func syncTeamUsers(cfg config.Config, c *api.GithubClient, team *github.Team, groups []string) {
+// syncTeamUsers - create missing users of {team} and follow renames recorded in {state},
+// return count of team members that have linux account
+func syncTeamUsers(cfg config.Config, c *api.GithubClient, state *accounts.State, team *github.Team, groups []string) (int, error) {
 	logger := log.WithFields(log.Fields{"subsystem": "jobs", "job": "syncTeamUsers"})
-	linux := api.NewLinux(cfg.Root)
+	linux := api.NewConfiguredLinux(cfg)
 
 	log.Info("SyncTeamUsers")
 
@@ -78,40 +147,84 @@ func syncTeamUsers(cfg config.Config, c *api.GithubClient, team *github.Team, gr
 	githubUsers, err := c.GetTeamMembers(team)
 	if err != nil {
 		logger.Error(err)
-		return
+		return 0, err
 	}
 
 	// Track users that were unable to be added to the system
 	notCreatedUsers := make([]string, 0)
+	mapping := cfg.NameMapping()
 
 	for _, githubUser := range githubUsers {
 		log.Info(*githubUser.Login)
-		linuxUser := model.NewUser(*githubUser.Login, "999", groups, cfg.UserShell)
+		login := strings.ToLower(githubUser.GetLogin())
+		linuxUser := model.NewUser(mapping.LinuxName(login), "999", groups, cfg.UserShell)
+		gecos := profileGecos(cfg, c, login)
+
+		if state != nil {
+			account := followRename(cfg, linux, state, githubUser.GetID(), login, linuxUser.Name())
+			state.Set(account)
+			if account.Linux != linuxUser.Name() {
+				logger.Debugf("User %v has account %v - skip creation", login, account.Linux)
+				updateGecos(linux, account.Linux, gecos)
+				continue
+			}
+		}
+
 		// Only add new users
 		if !linux.UserExists(linuxUser.Name()) {
+			if cfg.UserUIDSpan > 0 {
+				// Derived uid is the same on every host, user gets personal group with the same gid
+				uid, err := linux.AssignID(githubUser.GetID(), cfg.UserUIDBase, cfg.UserUIDSpan, cfg.UserUIDCollision)
+				if err != nil {
+					logger.Errorf("Unable to assign uid to user %v: %v", linuxUser.Name(), err)
+					notCreatedUsers = append(notCreatedUsers, linuxUser.Name())
+					continue
+				}
+				if uid != "" {
+					linuxUser = model.NewUser(linuxUser.Name(), "", groups, cfg.UserShell)
+					linuxUser.SetUid(uid)
+				}
+			}
+			linuxUser.SetGecos(gecos)
+
 			// Create user and track if we failed to create their account
 			if err := linux.UserCreate(linuxUser); err != nil {
 				logger.Error(err)
 				notCreatedUsers = append(notCreatedUsers, linuxUser.Name())
 			}
 		} else {
-			logger.Debugf("User %v exists - skip creation", *githubUser.Login)
+			logger.Debugf("User %v exists - skip creation", linuxUser.Name())
+			updateGecos(linux, linuxUser.Name(), gecos)
 		}
 	}
 
+	return len(githubUsers) - len(notCreatedUsers), nil
 }
 
 func sshIntegrate(cfg config.Config) {
 	logger := log.WithFields(log.Fields{"subsystem": "jobs", "job": "sshIntegrate"})
-	linux := api.NewLinux(cfg.Root)
+	linux := api.NewConfiguredLinux(cfg)
 
-	// Split listen string by : and get the port
-	port := strings.Split(cfg.Listen, ":")[1]
+	var wrapperScript string
 
-	wrapperScript := fasttemplate.New(wrapperScriptTpl, "{", "}").
-		ExecuteString(map[string]interface{}{"port": port})
+	if cfg.ListenSocket != "" {
+		// sshd runs outside of root dir, so it sees socket without root prefix
+		socket := cfg.ListenSocket
+		if root := strings.TrimRight(cfg.Root, "/"); root != "" && strings.HasPrefix(socket, root+"/") {
+			socket = strings.TrimPrefix(socket, root)
+		}
+
+		wrapperScript = fasttemplate.New(socketWrapperScriptTpl, "{", "}").
+			ExecuteString(map[string]interface{}{"socket": socket})
+	} else {
+		// Split listen string by : and get the port
+		port := cfg.Listen[strings.LastIndex(cfg.Listen, ":")+1:]
+
+		wrapperScript = fasttemplate.New(wrapperScriptTpl, "{", "}").
+			ExecuteString(map[string]interface{}{"port": port})
+	}
 
-	cmdFile := viper.GetString("authorized_keys_command_tpl")
+	cmdFile := cfg.AuthorizedKeysCommand
 
 	logger.Infof("Ensure file %v", cmdFile)
 	linux.FileEnsure(cmdFile, wrapperScript)
@@ -127,7 +240,12 @@ func sshIntegrate(cfg config.Config) {
 	linux.FileEnsureLineMatch("/etc/ssh/sshd_config", "(?m:^AuthorizedKeysCommandUser\\s.*$)", "AuthorizedKeysCommandUser nobody")
 
 	logger.Info("Restart ssh")
-	output, err := linux.TemplateCommand(viper.GetString("ssh_restart_tpl"), map[string]interface{}{}).CombinedOutput()
+	cmd, err := linux.TemplateCommand(linux.Template("ssh_restart_tpl"), map[string]interface{}{})
+	if err != nil {
+		logger.Errorf("Error: %v", err.Error())
+		return
+	}
+	output, err := cmd.CombinedOutput()
 	logger.Infof("Output: %v", string(output))
 	if err != nil {
 		logger.Errorf("Error: %v", err.Error())
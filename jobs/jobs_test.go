@@ -0,0 +1,44 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Stop()", func() {
+	It("should wait for sync in progress", func() {
+		// Sync in progress holds the mutex
+		syncMutex.Lock()
+
+		stopped := make(chan error, 1)
+		go func() { stopped <- Stop(context.Background()) }()
+
+		Consistently(stopped, 100*time.Millisecond).ShouldNot(Receive())
+		syncMutex.Unlock()
+		Eventually(stopped).Should(Receive(BeNil()))
+
+		// Mutex is released, so later sync is not blocked
+		Expect(syncMutex.TryLock()).To(BeTrue())
+		syncMutex.Unlock()
+	})
+
+	It("should give up when context is done", func() {
+		syncMutex.Lock()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		Expect(Stop(ctx)).To(Equal(context.DeadlineExceeded))
+
+		syncMutex.Unlock()
+		Eventually(func() bool {
+			if syncMutex.TryLock() {
+				syncMutex.Unlock()
+				return true
+			}
+			return false
+		}).Should(BeTrue())
+	})
+})
@@ -0,0 +1,84 @@
+package jobs
+
+import (
+	log "github.com/sirupsen/logrus"
+	"github.com/terjekv/github-authorized-keys/accounts"
+	"github.com/terjekv/github-authorized-keys/api"
+	"github.com/terjekv/github-authorized-keys/audit"
+	"github.com/terjekv/github-authorized-keys/config"
+	"github.com/terjekv/github-authorized-keys/metrics"
+)
+
+// loadState - return state of managed users, nil when state file is not set or could not be read
+func loadState(cfg config.Config) *accounts.State {
+	logger := log.WithFields(log.Fields{"subsystem": "jobs", "job": "loadState"})
+
+	if cfg.StatePath() == "" {
+		return nil
+	}
+
+	state, err := accounts.Load(cfg.StatePath())
+	if err != nil {
+		// State is not overwritten, so it could be fixed by hand
+		logger.Errorf("Unable to read state of managed users, renames are not followed: %v", err)
+		return nil
+	}
+	return state
+}
+
+// followRename - return linux account of GitHub user {githubID} with login {login} mapped to linux user {name}.
+// When the login changed since account was created, account is renamed or kept as alias according to rename policy.
+// Changed mapping rules alone do not rename accounts, the user gets a new account with the new name.
+// Account is returned unchanged when the rename failed, so it is tried again on the next sync.
+func followRename(cfg config.Config, linux api.Linux, state *accounts.State, githubID int64, login, name string) accounts.Account {
+	logger := log.WithFields(log.Fields{"subsystem": "jobs", "job": "followRename", "user": login})
+
+	current := accounts.Account{GithubID: githubID, Login: login, Linux: name}
+	if cfg.RenamePolicy == accounts.RenamePolicyNone {
+		return current
+	}
+
+	account, ok := state.Get(githubID)
+	if !ok || account.Linux == name || !linux.UserExists(account.Linux) {
+		return current
+	}
+
+	if account.Login == login && !account.Alias {
+		logger.Debugf("User name of %v changed from %v to %v by mapping rules", login, account.Linux, name)
+		return current
+	}
+
+	record := audit.Record{User: login, PreviousUser: account.Login, GithubID: githubID}
+
+	if cfg.RenamePolicy == accounts.RenamePolicyAlias {
+		if account.Login != login {
+			logger.Infof("GitHub user %v was renamed to %v, keep linux account %v", account.Login, login, account.Linux)
+			record.Event = audit.EventAccountAliased
+			logRecord(record)
+		}
+		return accounts.Account{GithubID: githubID, Login: login, Linux: account.Linux, Alias: true}
+	}
+
+	if linux.UserExists(name) {
+		logger.Errorf("GitHub user %v was renamed to %v, but linux account %v already exists", account.Login, login, name)
+		return account
+	}
+
+	if err := linux.UserRename(account.Linux, name); err != nil {
+		logger.Errorf("Unable to rename linux account %v to %v: %v", account.Linux, name, err)
+		return account
+	}
+
+	logger.Infof("GitHub user %v was renamed to %v, linux account %v renamed to %v", account.Login, login, account.Linux, name)
+	// Account with the old name is retired, aliased accounts keep their name and are not counted
+	metrics.UsersRemoved.Inc()
+	record.Event = audit.EventAccountRenamed
+	logRecord(record)
+	return current
+}
+
+func logRecord(record audit.Record) {
+	if err := audit.Log(record); err != nil {
+		log.WithFields(log.Fields{"subsystem": "jobs", "job": "logRecord"}).Errorf("Unable to write audit record: %v", err)
+	}
+}
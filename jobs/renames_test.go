@@ -0,0 +1,123 @@
+package jobs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/terjekv/github-authorized-keys/accounts"
+	"github.com/terjekv/github-authorized-keys/api"
+	"github.com/terjekv/github-authorized-keys/audit"
+	"github.com/terjekv/github-authorized-keys/config"
+	"github.com/terjekv/github-authorized-keys/metrics"
+)
+
+var _ = Describe("Renames", func() {
+	var (
+		root  string
+		linux api.Linux
+		state *accounts.State
+	)
+
+	auditLog := func() string {
+		content, _ := ioutil.ReadFile(filepath.Join(root, "audit.log"))
+		return string(content)
+	}
+
+	BeforeEach(func() {
+		var err error
+		root, err = ioutil.TempDir("", "gak-renames")
+		Expect(err).To(BeNil())
+
+		Expect(os.MkdirAll(filepath.Join(root, "etc"), 0755)).To(BeNil())
+		Expect(os.MkdirAll(filepath.Join(root, "home/alice"), 0755)).To(BeNil())
+		files := map[string]string{
+			"etc/passwd":  "root:x:0:0:root:/root:/bin/bash\nalice:x:1000:1000::/home/alice:/bin/bash\n",
+			"etc/shadow":  "root:*:19000:0:99999:7:::\nalice:*:19000:0:99999:7:::\n",
+			"etc/group":   "root:x:0:\nalice:x:1000:\n",
+			"etc/gshadow": "root:::\nalice:!::\n",
+		}
+		for name, content := range files {
+			Expect(ioutil.WriteFile(filepath.Join(root, name), []byte(content), 0644)).To(BeNil())
+		}
+
+		linux = api.NewConfiguredLinux(config.Config{Root: root, UserBackend: api.UserBackendNative})
+
+		Expect(audit.Setup(audit.Options{Output: audit.OutputFile, File: filepath.Join(root, "audit.log")})).To(BeNil())
+
+		state, err = accounts.Load(filepath.Join(root, "users.json"))
+		Expect(err).To(BeNil())
+		state.Set(accounts.Account{GithubID: 42, Login: "alice", Linux: "alice"})
+	})
+
+	AfterEach(func() {
+		audit.Setup(audit.Options{})
+		os.RemoveAll(root)
+	})
+
+	Describe("followRename()", func() {
+		Context("call with policy rename", func() {
+			cfg := config.Config{RenamePolicy: accounts.RenamePolicyRename}
+
+			It("should rename account when login changed", func() {
+				removed := testutil.ToFloat64(metrics.UsersRemoved)
+				account := followRename(cfg, linux, state, 42, "alice2", "alice2")
+				Expect(testutil.ToFloat64(metrics.UsersRemoved)).To(Equal(removed + 1))
+				Expect(account).To(Equal(accounts.Account{GithubID: 42, Login: "alice2", Linux: "alice2"}))
+				Expect(linux.UserExists("alice2")).To(BeTrue())
+				Expect(linux.UserExists("alice")).To(BeFalse())
+				Expect(auditLog()).To(ContainSubstring(audit.EventAccountRenamed))
+			})
+
+			It("should not rename account when only mapping changed", func() {
+				account := followRename(cfg, linux, state, 42, "alice", "gh_alice")
+				Expect(account).To(Equal(accounts.Account{GithubID: 42, Login: "alice", Linux: "gh_alice"}))
+				Expect(linux.UserExists("alice")).To(BeTrue())
+				Expect(linux.UserExists("gh_alice")).To(BeFalse())
+				Expect(auditLog()).To(BeEmpty())
+			})
+
+			It("should keep account to retry when new name is taken", func() {
+				passwd := filepath.Join(root, "etc/passwd")
+				content, _ := ioutil.ReadFile(passwd)
+				Expect(ioutil.WriteFile(passwd, append(content, []byte("alice2:x:1001:1001::/home/alice2:/bin/bash\n")...), 0644)).To(BeNil())
+
+				account := followRename(cfg, linux, state, 42, "alice2", "alice2")
+				Expect(account.Login).To(Equal("alice"))
+				Expect(account.Linux).To(Equal("alice"))
+			})
+		})
+
+		Context("call with policy alias", func() {
+			cfg := config.Config{RenamePolicy: accounts.RenamePolicyAlias}
+
+			It("should keep account as alias when login changed", func() {
+				account := followRename(cfg, linux, state, 42, "alice2", "alice2")
+				Expect(account).To(Equal(accounts.Account{GithubID: 42, Login: "alice2", Linux: "alice", Alias: true}))
+				Expect(linux.UserExists("alice")).To(BeTrue())
+				Expect(auditLog()).To(ContainSubstring(audit.EventAccountAliased))
+
+				state.Set(account)
+				Expect(followRename(cfg, linux, state, 42, "alice2", "alice2")).To(Equal(account))
+			})
+
+			It("should return new name when only mapping changed", func() {
+				account := followRename(cfg, linux, state, 42, "alice", "gh_alice")
+				Expect(account).To(Equal(accounts.Account{GithubID: 42, Login: "alice", Linux: "gh_alice"}))
+				Expect(auditLog()).To(BeEmpty())
+			})
+		})
+
+		Context("call with policy none", func() {
+			It("should return new name", func() {
+				cfg := config.Config{RenamePolicy: accounts.RenamePolicyNone}
+				account := followRename(cfg, linux, state, 42, "alice2", "alice2")
+				Expect(account).To(Equal(accounts.Account{GithubID: 42, Login: "alice2", Linux: "alice2"}))
+				Expect(linux.UserExists("alice")).To(BeTrue())
+			})
+		})
+	})
+})
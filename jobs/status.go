@@ -0,0 +1,61 @@
+package jobs
+
+import (
+	"sync"
+	"time"
+
+	"github.com/terjekv/github-authorized-keys/metrics"
+)
+
+const (
+	roleAdmin = "admin"
+	roleUser  = "user"
+)
+
+// SyncStatus - result of users synchronization runs
+type SyncStatus struct {
+	// LastRun - time when last synchronization started, zero if it never ran
+	LastRun time.Time `json:"last_run"`
+	// LastSuccess - time when last successful synchronization started
+	LastSuccess time.Time `json:"last_success"`
+	// LastError - error of last synchronization, empty when it succeeded
+	LastError string `json:"last_error,omitempty"`
+	// ManagedUsers - count of team members with linux account by role, as of last successful synchronization
+	ManagedUsers map[string]int `json:"managed_users"`
+}
+
+var status = struct {
+	sync.RWMutex
+	value SyncStatus
+}{value: SyncStatus{ManagedUsers: map[string]int{}}}
+
+// LastSync - return status of users synchronization
+func LastSync() SyncStatus {
+	status.RLock()
+	defer status.RUnlock()
+
+	result := status.value
+	result.ManagedUsers = map[string]int{}
+	for role, count := range status.value.ManagedUsers {
+		result.ManagedUsers[role] = count
+	}
+	return result
+}
+
+func recordSync(startedAt time.Time, managedUsers map[string]int, err error) {
+	status.Lock()
+	defer status.Unlock()
+
+	metrics.SyncDuration.Observe(time.Since(startedAt).Seconds())
+
+	status.value.LastRun = startedAt
+	if err != nil {
+		metrics.SyncFailures.Inc()
+		status.value.LastError = err.Error()
+		return
+	}
+
+	status.value.LastError = ""
+	status.value.LastSuccess = startedAt
+	status.value.ManagedUsers = managedUsers
+}
@@ -0,0 +1,35 @@
+package jobs
+
+import (
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/terjekv/github-authorized-keys/metrics"
+)
+
+var _ = Describe("Status", func() {
+	It("should record failed sync and count it", func() {
+		failures := testutil.ToFloat64(metrics.SyncFailures)
+
+		recordSync(time.Now(), nil, errors.New("GitHub is down"))
+
+		Expect(testutil.ToFloat64(metrics.SyncFailures)).To(Equal(failures + 1))
+		Expect(LastSync().LastError).To(Equal("GitHub is down"))
+	})
+
+	It("should record successful sync with managed users", func() {
+		startedAt := time.Now()
+		failures := testutil.ToFloat64(metrics.SyncFailures)
+
+		recordSync(startedAt, map[string]int{"admin": 2}, nil)
+
+		Expect(testutil.ToFloat64(metrics.SyncFailures)).To(Equal(failures))
+		last := LastSync()
+		Expect(last.LastError).To(BeEmpty())
+		Expect(last.LastSuccess).To(Equal(startedAt))
+		Expect(last.ManagedUsers).To(Equal(map[string]int{"admin": 2}))
+	})
+})
@@ -0,0 +1,168 @@
+package keyStorages
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// EntryVersion - version of cache entry record written by this release
+const EntryVersion = 1
+
+// ErrEntryVersionUnsupported - returned when cache entry was written by newer release
+var ErrEntryVersionUnsupported = errors.New("storage: Unsupported cache entry version")
+
+const (
+	// SourceGithub - entry fetched from github.com API
+	SourceGithub = "github"
+
+	// SourceLegacy - entry decoded from plain newline-joined keys written by older releases
+	SourceLegacy = "legacy"
+)
+
+const (
+	// RoleAdmin - user is a member of admin team
+	RoleAdmin = "admin"
+
+	// RoleUser - user is a member of user team
+	RoleUser = "user"
+)
+
+// Key - public ssh key with metadata
+type Key struct {
+	ID          int64      `json:"id,omitempty"`
+	Key         string     `json:"key"`
+	Fingerprint string     `json:"fingerprint,omitempty"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+}
+
+// Entry - user's public keys with metadata, stored in fallback cache as versioned JSON record
+type Entry struct {
+	Version      int       `json:"version"`
+	Login        string    `json:"login"`
+	GithubUserID int64     `json:"github_user_id,omitempty"`
+	Team         string    `json:"team,omitempty"`
+	Role         string    `json:"role,omitempty"`
+	Keys         []Key     `json:"keys"`
+	FetchedAt    time.Time `json:"fetched_at"`
+	Source       string    `json:"source"`
+
+	// Cached - entry was served from fallback cache, never stored
+	Cached bool `json:"-"`
+}
+
+// NewKey - creates Key and calculates SHA256 fingerprint of {key}
+func NewKey(id int64, key string) Key {
+	return Key{ID: id, Key: key, Fingerprint: Fingerprint(key)}
+}
+
+// Fingerprint - return SHA256 fingerprint of public key in authorized_keys format or empty string for malformed key
+func Fingerprint(key string) string {
+	publicKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(key))
+	if err != nil {
+		return ""
+	}
+	return ssh.FingerprintSHA256(publicKey)
+}
+
+// Type - return key type (e.g. ssh-ed25519) or empty string for malformed key
+func (k *Key) Type() string {
+	publicKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(k.Key))
+	if err != nil {
+		return ""
+	}
+	return publicKey.Type()
+}
+
+// Bits - return key length in bits or 0 for malformed key
+func (k *Key) Bits() int {
+	publicKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(k.Key))
+	if err != nil {
+		return 0
+	}
+
+	cryptoKey, ok := publicKey.(ssh.CryptoPublicKey)
+	if !ok {
+		return 0
+	}
+
+	switch key := cryptoKey.CryptoPublicKey().(type) {
+	case *rsa.PublicKey:
+		return key.N.BitLen()
+	case *ecdsa.PublicKey:
+		return key.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return len(key) * 8
+	default:
+		return 0
+	}
+}
+
+// FindKey - return key with SHA256 {fingerprint} as passed by sshd in %f.
+// When {keyType} is not empty key type should match as well.
+func (e *Entry) FindKey(fingerprint, keyType string) (Key, bool) {
+	for _, key := range e.Keys {
+		// Records written before fingerprints were stored have them empty
+		if key.Fingerprint == "" {
+			key.Fingerprint = Fingerprint(key.Key)
+		}
+		if key.Fingerprint != "" && key.Fingerprint == fingerprint && (keyType == "" || key.Type() == keyType) {
+			return key, true
+		}
+	}
+	return Key{}, false
+}
+
+// AuthorizedKeys - return keys in authorized_keys file format
+func (e *Entry) AuthorizedKeys() string {
+	result := []string{}
+	for _, key := range e.Keys {
+		result = append(result, key.Key)
+	}
+	return strings.Join(result, "\n")
+}
+
+// Fingerprints - return SHA256 fingerprints of all keys
+func (e *Entry) Fingerprints() []string {
+	result := []string{}
+	for _, key := range e.Keys {
+		result = append(result, key.Fingerprint)
+	}
+	return result
+}
+
+// Marshal - encode entry as JSON record
+func (e *Entry) Marshal() (string, error) {
+	e.Version = EntryVersion
+	value, err := json.Marshal(e)
+	return string(value), err
+}
+
+// UnmarshalEntry - decode cached {value} of user {name}.
+// Values that are not JSON records are treated as plain newline-joined keys written by older releases.
+func UnmarshalEntry(name, value string) (*Entry, error) {
+	if strings.HasPrefix(value, "{") {
+		entry := &Entry{}
+		if err := json.Unmarshal([]byte(value), entry); err != nil {
+			return nil, err
+		}
+		if entry.Version > EntryVersion {
+			return nil, ErrEntryVersionUnsupported
+		}
+		return entry, nil
+	}
+
+	entry := &Entry{Login: name, Source: SourceLegacy, Keys: []Key{}}
+	for _, line := range strings.Split(value, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			entry.Keys = append(entry.Keys, NewKey(0, line))
+		}
+	}
+	return entry, nil
+}
@@ -0,0 +1,104 @@
+package keyStorages
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Entry", func() {
+	const (
+		validKey         = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIGIM7Pr9yDRXFTT0pCnsEd1ajJb4wEM5RwT7wyoFpgFo test@example"
+		validFingerprint = "SHA256:vuY8Q3gdtaW/t7g5mOIbZbrRnFHDmWBiSOaNYn82ph0"
+	)
+
+	Describe("UnmarshalEntry()", func() {
+		Context("call with plain keys written by older release", func() {
+			It("should return legacy entry with fingerprints", func() {
+				entry, err := UnmarshalEntry("goruha", validKey+"\n\nssh-rsa broken")
+
+				Expect(err).To(BeNil())
+				Expect(entry.Source).To(Equal(SourceLegacy))
+				Expect(entry.Login).To(Equal("goruha"))
+				Expect(entry.FetchedAt.IsZero()).To(BeTrue())
+				Expect(entry.Fingerprints()).To(Equal([]string{validFingerprint, ""}))
+				Expect(entry.AuthorizedKeys()).To(Equal(validKey + "\nssh-rsa broken"))
+			})
+		})
+
+		Context("call with marshaled record", func() {
+			It("should return same entry", func() {
+				record := &Entry{Login: "goruha", GithubUserID: 42, Team: "ssh", Role: RoleAdmin, Keys: []Key{NewKey(7, validKey)}, Source: SourceGithub}
+				value, err := record.Marshal()
+				Expect(err).To(BeNil())
+
+				entry, err := UnmarshalEntry("goruha", value)
+
+				Expect(err).To(BeNil())
+				Expect(entry).To(Equal(record))
+				Expect(entry.Keys[0].Fingerprint).To(Equal(validFingerprint))
+			})
+		})
+
+		Context("call with record of unsupported version", func() {
+			It("should return valid error", func() {
+				_, err := UnmarshalEntry("goruha", `{"version": 99, "keys": []}`)
+
+				Expect(err).To(Equal(ErrEntryVersionUnsupported))
+			})
+		})
+	})
+
+	Describe("FindKey()", func() {
+		var entry *Entry
+
+		BeforeEach(func() {
+			entry = &Entry{Login: "goruha", Keys: []Key{{Key: "ssh-rsa broken"}, NewKey(7, validKey)}}
+		})
+
+		It("should return key with matching fingerprint", func() {
+			key, found := entry.FindKey(validFingerprint, "")
+
+			Expect(found).To(BeTrue())
+			Expect(key.ID).To(Equal(int64(7)))
+		})
+
+		It("should check key type when set", func() {
+			_, found := entry.FindKey(validFingerprint, "ssh-ed25519")
+			Expect(found).To(BeTrue())
+
+			_, found = entry.FindKey(validFingerprint, "ssh-rsa")
+			Expect(found).To(BeFalse())
+		})
+
+		It("should match keys without stored fingerprint", func() {
+			entry.Keys[1].Fingerprint = ""
+
+			_, found := entry.FindKey(validFingerprint, "ssh-ed25519")
+
+			Expect(found).To(BeTrue())
+		})
+
+		It("should not match unknown fingerprint", func() {
+			_, found := entry.FindKey("SHA256:unknown", "")
+
+			Expect(found).To(BeFalse())
+		})
+	})
+
+	Describe("Key", func() {
+		It("should return type and bit length", func() {
+			key := NewKey(7, validKey)
+
+			Expect(key.Type()).To(Equal("ssh-ed25519"))
+			Expect(key.Bits()).To(Equal(256))
+		})
+
+		It("should return zero values for malformed key", func() {
+			key := NewKey(7, "ssh-rsa broken")
+
+			Expect(key.Type()).To(Equal(""))
+			Expect(key.Bits()).To(Equal(0))
+			Expect(key.Fingerprint).To(Equal(""))
+		})
+	})
+})
@@ -2,68 +2,113 @@ package keyStorages
 
 import (
 	"errors"
-	"strings"
+	"time"
 
+	"github.com/google/go-github/v43/github"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/terjekv/github-authorized-keys/api"
 )
 
+// githubClient - GitHub API calls used by GithubKeys
+type githubClient interface {
+	GetTeam(name string, id int) (*github.Team, error)
+	IsTeamMember(user string, team *github.Team) (bool, error)
+	GetKeys(userName string) ([]*github.Key, error)
+	GetUser(name string) (*github.User, error)
+}
+
 // GithubKeys - github api as key storage
 type GithubKeys struct {
-	client      *api.GithubClient
+	client      githubClient
 	Adminteam   string
 	AdminteamID int
 	Userteam    string
 	UserteamID  int
+
+	// Profile - fetch GitHub profile for entry metadata, costs one more API request per lookup
+	Profile bool
 }
 
 // Get - fetch {user} ssh keys
 func (s *GithubKeys) Get(user string) (value string, err error) {
+	entry, err := s.GetEntry(user)
+	if err != nil {
+		return "", err
+	}
+	return entry.AuthorizedKeys(), nil
+}
+
+// GetEntry - fetch {user} ssh keys with team membership and profile metadata
+func (s *GithubKeys) GetEntry(user string) (entry *Entry, err error) {
 	defer func() {
 		if r := recover(); r != nil {
-			value = ""
+			entry = nil
 			err = ErrStorageConnectionFailed
 		}
 	}()
 
-	value = ""
-
-	logger := log.WithFields(log.Fields{"class": "github_keys", "method": "Get"})
+	logger := log.WithFields(log.Fields{"class": "github_keys", "method": "GetEntry"})
 	log.SetLevel(log.DebugLevel)
 
 	logger.Debugf("starting lookup %v", user)
 
+	entry = &Entry{Login: user, Source: SourceGithub, Keys: []Key{}}
+
 	logger.Debugf("checking admin membership")
-	aisMember, _ := isMemberOf(s, user, s.Adminteam, s.AdminteamID)
+	isMember, err := isMemberOf(s, user, s.Adminteam, s.AdminteamID)
+	if err == ErrStorageConnectionFailed {
+		return nil, err
+	}
 
-	if !aisMember {
+	if isMember {
+		entry.Team, entry.Role = s.Adminteam, RoleAdmin
+	} else if s.Userteam != "" || s.UserteamID != 0 {
 		logger.Debugf("checking user membership")
-		uisMember, _ := isMemberOf(s, user, s.Adminteam, s.AdminteamID)
-		if !uisMember {
-			//			uerr = ErrStorageKeyNotFound
-			logger.Debugf("no memberships for %v", user)
-			return
+		isMember, err = isMemberOf(s, user, s.Userteam, s.UserteamID)
+		if err == ErrStorageConnectionFailed {
+			return nil, err
 		}
+		entry.Team, entry.Role = s.Userteam, RoleUser
+	}
+
+	if !isMember {
+		logger.Debugf("no memberships for %v", user)
+		return nil, ErrStorageNotMember
 	}
 
 	// we have some membership, get keys etc.
 	keys, err := s.client.GetKeys(user)
 
 	if err == nil {
-		result := []string{}
 		for _, value := range keys {
-			result = append(result, *value.Key)
+			key := NewKey(value.GetID(), value.GetKey())
+			if value.CreatedAt != nil {
+				createdAt := value.CreatedAt.UTC()
+				key.CreatedAt = &createdAt
+			}
+			entry.Keys = append(entry.Keys, key)
 		}
-		value = strings.Join(result, "\n")
-
 	} else if err == api.ErrorGitHubNotFound {
-		err = ErrStorageKeyNotFound
+		return nil, ErrStorageKeyNotFound
+	} else if err == api.ErrorGitHubConnectionFailed {
+		return nil, ErrStorageConnectionFailed
 	} else {
-		err = errors.New("access denied")
+		return nil, errors.New("access denied")
+	}
+
+	// Profile is used only for metadata, so failure does not fail lookup
+	if s.Profile {
+		if profile, profileErr := s.client.GetUser(user); profileErr == nil {
+			entry.GithubUserID = profile.GetID()
+			entry.Login = profile.GetLogin()
+		} else {
+			logger.Debugf("unable to fetch profile of %v: %v", user, profileErr)
+		}
 	}
 
-	return
+	entry.FetchedAt = time.Now().UTC()
+	return entry, nil
 }
 
 func isMemberOf(s *GithubKeys, user string, teamname string, teamid int) (isMember bool, err error) {
@@ -73,31 +118,31 @@ func isMemberOf(s *GithubKeys, user string, teamname string, teamid int) (isMemb
 	logger.Debugf("fetching team %v/%d", teamname, teamid)
 	team, err := s.client.GetTeam(teamname, teamid)
 	if err != nil {
-		if err == api.ErrorGitHubConnectionFailed {
-			err = ErrStorageConnectionFailed
-		} else {
+		// Rate limits, bad tokens and server errors make GitHub unavailable, so cached keys could still be served
+		if err == api.ErrorGitHubTeamNotFound || err == api.ErrorGitHubNotFound {
 			err = ErrStorageKeyNotFound
+		} else {
+			err = ErrStorageConnectionFailed
 		}
 		return false, err
 	}
 
 	logger.Debugf("checking is %v is in team %v", user, teamname)
+	// Non-members are reported without error, any error means membership is unknown
 	isMember, mem_err := s.client.IsTeamMember(user, team)
 	if mem_err != nil {
-		if mem_err == api.ErrorGitHubConnectionFailed {
-			mem_err = ErrStorageConnectionFailed
-		} else {
-			mem_err = ErrStorageKeyNotFound
-		}
-		logger.Debugf("looks like is %v is a member of team %v!", user, teamname)
+		logger.Debugf("unable to check membership of %v in team %v: %v", user, teamname, mem_err)
+		mem_err = ErrStorageConnectionFailed
 	}
 	return isMember, mem_err
 }
 
-// NewGithubKeys - constructor for github key storage
-func NewGithubKeys(token, owner, Adminteam string, AdminteamID int, Userteam string, UserteamID int) *GithubKeys {
+// NewGithubKeys - constructor for github key storage, lists are requested by {pageSize} items
+func NewGithubKeys(token, owner, Adminteam string, AdminteamID int, Userteam string, UserteamID int, pageSize int) *GithubKeys {
+	client := api.NewGithubClient(token, owner)
+	client.SetPageSize(pageSize)
 	return &GithubKeys{
-		client:    api.NewGithubClient(token, owner),
+		client:    client,
 		Adminteam: Adminteam, AdminteamID: AdminteamID,
 		Userteam: Userteam, UserteamID: UserteamID,
 	}
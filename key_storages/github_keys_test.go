@@ -5,6 +5,7 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/spf13/viper"
+	"github.com/terjekv/github-authorized-keys/api"
 )
 
 var _ = Describe("GithubKeys as backend storage", func() {
@@ -32,7 +33,7 @@ var _ = Describe("GithubKeys as backend storage", func() {
 		var c *GithubKeys
 
 		BeforeEach(func() {
-			c = NewGithubKeys(validToken, validOrg, validAdminTeamName, validAdminTeamID, validUserTeamName, validUserTeamID)
+			c = NewGithubKeys(validToken, validOrg, validAdminTeamName, validAdminTeamID, validUserTeamName, validUserTeamID, api.GithubAPIMaxPageSizeDefault)
 		})
 
 		Context("backend have valid value", func() {
@@ -52,7 +53,7 @@ var _ = Describe("GithubKeys as backend storage", func() {
 				keys, err := c.Get("djahsjdhadafdsgfhdgahfjd")
 
 				Expect(err).NotTo(BeNil())
-				Expect(err).To(Equal(ErrStorageKeyNotFound))
+				Expect(err).To(MatchError(ErrStorageKeyNotFound))
 				Expect(keys).To(Equal(""))
 			})
 		})
@@ -64,7 +65,7 @@ var _ = Describe("GithubKeys as backend storage", func() {
 
 		BeforeEach(func() {
 			httpmock.Activate()
-			c = NewGithubKeys(validToken, validOrg, validAdminTeamName, validAdminTeamID, validUserTeamName, validUserTeamID)
+			c = NewGithubKeys(validToken, validOrg, validAdminTeamName, validAdminTeamID, validUserTeamName, validUserTeamID, api.GithubAPIMaxPageSizeDefault)
 
 		})
 
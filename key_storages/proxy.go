@@ -2,8 +2,11 @@ package keyStorages
 
 import (
 	"errors"
+	"fmt"
+	"time"
 
 	log "github.com/sirupsen/logrus"
+	"github.com/terjekv/github-authorized-keys/metrics"
 )
 
 var (
@@ -12,70 +15,161 @@ var (
 
 	// ErrStorageConnectionFailed - returned when there was connection error to storage (source or fallback cache)
 	ErrStorageConnectionFailed = errors.New("storage: Connection failed")
+
+	// ErrStorageKeyStale - returned when cached value is older than allowed by staleness policy
+	ErrStorageKeyStale = errors.New("storage: Cached key is stale")
+
+	// ErrStorageNotMember - returned when user is not a member of any team, it is also ErrStorageKeyNotFound
+	ErrStorageNotMember = fmt.Errorf("%w: user is not a team member", ErrStorageKeyNotFound)
 )
 
-type fallbackCache interface {
+// FallbackCache - key storage used by Proxy to keep values when source key storage is unavailable
+type FallbackCache interface {
 	Get(key string) (string, error)
 	Set(key, value string) error
 	Remove(key string) error
 }
 
 type source interface {
-	Get(key string) (string, error)
+	GetEntry(key string) (*Entry, error)
 }
 
 // Proxy - key storage fallback proxy.
 // 	Always deal with source key storage first, and sync values with fallback cache storage
 //      If source key storage is unavailable fallback to cache storage
 type Proxy struct {
-	fallbackCache fallbackCache
+	fallbackCache FallbackCache
 	source        source
+	staleness     StalenessPolicy
+}
+
+// StalenessPolicy - limits age of values served from fallback cache when source key storage is unavailable
+type StalenessPolicy struct {
+	// MaxStaleness - limit for all roles, zero means values are served until cache ttl expires
+	MaxStaleness time.Duration
+	// Roles - per role limits, they only tighten MaxStaleness, zero or missing role falls back to MaxStaleness
+	Roles map[string]time.Duration
+}
+
+// Limit - return maximum allowed age of cached value for {role}, the smaller of role and global limits
+func (p StalenessPolicy) Limit(role string) time.Duration {
+	limit := p.Roles[role]
+	if limit <= 0 || (p.MaxStaleness > 0 && p.MaxStaleness < limit) {
+		return p.MaxStaleness
+	}
+	return limit
 }
 
 // Get - fetch value from key storage
 func (c *Proxy) Get(name string) (value string, err error) {
-	logger := log.WithFields(log.Fields{"class": "Proxy", "method": "Get"})
+	entry, err := c.GetEntry(name)
+	if err != nil {
+		return "", err
+	}
+	return entry.AuthorizedKeys(), nil
+}
+
+// GetEntry - fetch keys with metadata from key storage
+func (c *Proxy) GetEntry(name string) (entry *Entry, err error) {
+	logger := log.WithFields(log.Fields{"class": "Proxy", "method": "GetEntry"})
 	log.SetLevel(log.DebugLevel)
 
 	logger.Debugf("Backend lookup %v", name)
 
-	value, err = c.lookupIn(c.source, name)
+	entry, err = c.lookupIn(c.source, name)
 
-	switch err {
-	case nil:
+	switch {
+	case err == nil:
 		logger.Debugf("Backend found %v", name)
-		c.saveTo(c.fallbackCache, name, value)
+		c.saveTo(c.fallbackCache, name, entry)
 		return
 
-	case ErrStorageKeyNotFound:
+	case errors.Is(err, ErrStorageKeyNotFound):
 		c.removeFrom(c.fallbackCache, name)
 		return
 
 	default:
 		logger.Debug("Backend failed")
 		logger.Debug("Fallback to cache")
-		value, err = c.fallbackCache.Get(name)
-		return
+		entry, err = c.loadFrom(c.fallbackCache, name)
+		if err != nil {
+			return
+		}
+		return c.checkStaleness(name, entry)
 	}
 }
 
-func (c *Proxy) lookupIn(storage source, name string) (string, error) {
-	return storage.Get(name)
+// SetStalenessPolicy - limit age of values served from fallback cache
+func (c *Proxy) SetStalenessPolicy(policy StalenessPolicy) {
+	c.staleness = policy
 }
 
-func (c *Proxy) saveTo(storage fallbackCache, name, value string) error {
+func (c *Proxy) checkStaleness(name string, entry *Entry) (*Entry, error) {
+	limit := c.staleness.Limit(entry.Role)
+	age := time.Since(entry.FetchedAt)
+
+	logger := log.WithFields(log.Fields{
+		"class":         "Proxy",
+		"method":        "checkStaleness",
+		"user":          name,
+		"role":          entry.Role,
+		"source":        entry.Source,
+		"fetched_at":    entry.FetchedAt,
+		"max_staleness": limit.String(),
+	})
+	if !entry.FetchedAt.IsZero() {
+		logger = logger.WithField("age", age.String())
+	}
+
+	// Legacy entries have no fetch time, so their age is unknown
+	if limit > 0 && (entry.FetchedAt.IsZero() || age > limit) {
+		logger.WithField("event", "stale_keys_rejected").Warnf("Cached keys of %v are older than allowed", name)
+		return nil, ErrStorageKeyStale
+	}
+
+	logger.WithField("event", "stale_keys_served").Warnf("Source unavailable, serving cached keys of %v", name)
+	return entry, nil
+}
+
+func (c *Proxy) lookupIn(storage source, name string) (*Entry, error) {
+	return storage.GetEntry(name)
+}
+
+func (c *Proxy) saveTo(storage FallbackCache, name string, entry *Entry) error {
 	logger := log.WithFields(log.Fields{"class": "Proxy", "method": "saveTo"})
+	value, err := entry.Marshal()
+	if err != nil {
+		return err
+	}
 	logger.Debugf("Saving to cache %v: %v", name, value)
 	return storage.Set(name, value)
 }
 
-func (c *Proxy) removeFrom(storage fallbackCache, name string) error {
+func (c *Proxy) loadFrom(storage FallbackCache, name string) (*Entry, error) {
+	logger := log.WithFields(log.Fields{"class": "Proxy", "method": "loadFrom"})
+	value, err := storage.Get(name)
+	if err != nil {
+		metrics.CacheLookups.WithLabelValues("miss").Inc()
+		return nil, err
+	}
+	entry, err := UnmarshalEntry(name, value)
+	if err != nil {
+		metrics.CacheLookups.WithLabelValues("miss").Inc()
+		logger.Errorf("Unable to decode cached value of %v: %v", name, err)
+		return nil, ErrStorageKeyNotFound
+	}
+	metrics.CacheLookups.WithLabelValues("hit").Inc()
+	entry.Cached = true
+	return entry, nil
+}
+
+func (c *Proxy) removeFrom(storage FallbackCache, name string) error {
 	logger := log.WithFields(log.Fields{"class": "Proxy", "method": "removeFrom"})
 	logger.Debugf("Remove %v from cache", name)
 	return storage.Remove(name)
 }
 
 // NewProxy - constructor to create Proxy object
-func NewProxy(source source, fallbackCache fallbackCache) *Proxy {
+func NewProxy(source source, fallbackCache FallbackCache) *Proxy {
 	return &Proxy{source: source, fallbackCache: fallbackCache}
 }
@@ -1,8 +1,13 @@
 package keyStorages
 
 import (
+	"errors"
+	"time"
+
+	"github.com/google/go-github/v43/github"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/terjekv/github-authorized-keys/api"
 )
 
 type CacheMap struct {
@@ -31,20 +36,64 @@ type BackendMap struct {
 	storage *map[string]string
 }
 
-func (c *BackendMap) Get(name string) (string, error) {
+func (c *BackendMap) GetEntry(name string) (*Entry, error) {
 	val, ok := (*c.storage)[name]
 	if !ok {
-		return "", ErrStorageKeyNotFound
+		return nil, ErrStorageKeyNotFound
 	}
-	return val, nil
+	return &Entry{Login: name, Role: RoleUser, Keys: []Key{{Key: val}}, FetchedAt: time.Now(), Source: SourceGithub}, nil
 }
 
 type BackendFail struct{}
 
-func (c *BackendFail) Get(name string) (string, error) {
-	return "", ErrStorageConnectionFailed
+func (c *BackendFail) GetEntry(name string) (*Entry, error) {
+	return nil, ErrStorageConnectionFailed
+}
+
+// GithubFail - GitHub client failing team lookups with {err}
+type GithubFail struct {
+	err error
+}
+
+func (c *GithubFail) GetTeam(name string, id int) (*github.Team, error) {
+	return nil, c.err
+}
+
+func (c *GithubFail) IsTeamMember(user string, team *github.Team) (bool, error) {
+	return false, c.err
+}
+
+func (c *GithubFail) GetKeys(userName string) ([]*github.Key, error) {
+	return nil, c.err
+}
+
+func (c *GithubFail) GetUser(name string) (*github.User, error) {
+	return nil, c.err
 }
 
+var _ = Describe("StalenessPolicy", func() {
+	policy := StalenessPolicy{
+		MaxStaleness: time.Hour,
+		Roles:        map[string]time.Duration{RoleAdmin: time.Minute, RoleUser: 2 * time.Hour},
+	}
+
+	It("should tighten global limit by role limit", func() {
+		Expect(policy.Limit(RoleAdmin)).To(Equal(time.Minute))
+	})
+
+	It("should not loosen global limit by role limit", func() {
+		Expect(policy.Limit(RoleUser)).To(Equal(time.Hour))
+	})
+
+	It("should use role limit without global limit", func() {
+		Expect(StalenessPolicy{Roles: policy.Roles}.Limit(RoleUser)).To(Equal(2 * time.Hour))
+	})
+
+	It("should use global limit for roles without limit", func() {
+		Expect(policy.Limit("")).To(Equal(time.Hour))
+	})
+})
+
 var _ = Describe("Proxy", func() {
 	var (
 		cacheStorage   map[string]string
@@ -72,13 +121,19 @@ var _ = Describe("Proxy", func() {
 			Expect(value).To(Equal("TestValue"))
 		})
 
-		It("should save value to cache", func() {
+		It("should save value to cache as versioned record", func() {
 			proxyStorage.Get("goruha")
 
 			value, ok := cacheStorage["goruha"]
 
 			Expect(ok).To(BeTrue())
-			Expect(value).To(Equal("TestValue"))
+
+			entry, err := UnmarshalEntry("goruha", value)
+			Expect(err).To(BeNil())
+			Expect(entry.Version).To(Equal(EntryVersion))
+			Expect(entry.Role).To(Equal(RoleUser))
+			Expect(entry.Source).To(Equal(SourceGithub))
+			Expect(entry.AuthorizedKeys()).To(Equal("TestValue"))
 		})
 	})
 
@@ -151,5 +206,104 @@ var _ = Describe("Proxy", func() {
 			Expect(err).To(BeNil())
 			Expect(value).To(Equal("TestValue"))
 		})
+
+		It("should return value with metadata when cache holds record", func() {
+			fetchedAt := time.Now().Add(-time.Hour).UTC()
+			record := &Entry{Login: "goruha", Role: RoleAdmin, Keys: []Key{{Key: "TestValue"}}, FetchedAt: fetchedAt, Source: SourceGithub}
+			cacheStorage["goruha"], _ = record.Marshal()
+
+			entry, err := proxyStorage.GetEntry("goruha")
+
+			Expect(err).To(BeNil())
+			Expect(entry.Role).To(Equal(RoleAdmin))
+			Expect(entry.FetchedAt.Equal(fetchedAt)).To(BeTrue())
+			Expect(entry.AuthorizedKeys()).To(Equal("TestValue"))
+		})
+	})
+
+	Context("backend failed to be connected and staleness policy is set", func() {
+		BeforeEach(func() {
+			cacheStorage = map[string]string{}
+
+			proxyStorage = Proxy{
+				fallbackCache: &CacheMap{storage: &cacheStorage},
+				source:        &BackendFail{},
+				staleness: StalenessPolicy{
+					MaxStaleness: time.Hour,
+					Roles:        map[string]time.Duration{RoleAdmin: time.Minute},
+				},
+			}
+		})
+
+		It("should return cached value younger than limit", func() {
+			record := &Entry{Login: "goruha", Role: RoleUser, Keys: []Key{{Key: "TestValue"}}, FetchedAt: time.Now().Add(-30 * time.Minute)}
+			cacheStorage["goruha"], _ = record.Marshal()
+
+			value, err := proxyStorage.Get("goruha")
+
+			Expect(err).To(BeNil())
+			Expect(value).To(Equal("TestValue"))
+		})
+
+		It("should reject cached value older than role limit", func() {
+			record := &Entry{Login: "goruha", Role: RoleAdmin, Keys: []Key{{Key: "TestValue"}}, FetchedAt: time.Now().Add(-30 * time.Minute)}
+			cacheStorage["goruha"], _ = record.Marshal()
+
+			value, err := proxyStorage.Get("goruha")
+
+			Expect(err).To(Equal(ErrStorageKeyStale))
+			Expect(value).To(Equal(""))
+		})
+
+		It("should reject legacy cached value with unknown age", func() {
+			cacheStorage["goruha"] = "TestValue"
+
+			value, err := proxyStorage.Get("goruha")
+
+			Expect(err).To(Equal(ErrStorageKeyStale))
+			Expect(value).To(Equal(""))
+		})
+	})
+
+	Context("GitHub fails with error other than connection error", func() {
+		var source *GithubKeys
+
+		BeforeEach(func() {
+			cacheStorage = map[string]string{}
+			source = &GithubKeys{Adminteam: "ssh"}
+
+			proxyStorage = Proxy{
+				fallbackCache: &CacheMap{storage: &cacheStorage},
+				source:        source,
+			}
+
+			record := &Entry{Login: "goruha", Role: RoleAdmin, Keys: []Key{{Key: "TestValue"}}, FetchedAt: time.Now()}
+			cacheStorage["goruha"], _ = record.Marshal()
+		})
+
+		for name, err := range map[string]error{
+			"rate limit or bad token": api.ErrorGitHubAccessDenied,
+			"server error":            errors.New("502 Bad Gateway"),
+		} {
+			err := err
+			It("should serve cached value and keep it on "+name, func() {
+				source.client = &GithubFail{err: err}
+
+				value, lookupErr := proxyStorage.Get("goruha")
+
+				Expect(lookupErr).To(BeNil())
+				Expect(value).To(Equal("TestValue"))
+				Expect(cacheStorage).To(HaveKey("goruha"))
+			})
+		}
+
+		It("should remove cached value when team does not exist", func() {
+			source.client = &GithubFail{err: api.ErrorGitHubTeamNotFound}
+
+			_, err := proxyStorage.Get("goruha")
+
+			Expect(err).To(MatchError(ErrStorageKeyNotFound))
+			Expect(cacheStorage).NotTo(HaveKey("goruha"))
+		})
 	})
 })
@@ -0,0 +1,122 @@
+package keyStorages
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	log "github.com/sirupsen/logrus"
+)
+
+// RedisCacheOptions - connection settings for redis based key storage
+type RedisCacheOptions struct {
+	// Endpoints - list of host:port redis addresses (sentinel addresses when MasterName is set)
+	Endpoints []string
+	// MasterName - name of the sentinel master, empty for standalone redis
+	MasterName string
+	Password   string
+	DB         int
+	// TLSConfig - enables TLS when not nil
+	TLSConfig *tls.Config
+	// Prefix - prepended to every key
+	Prefix string
+	// TTL - expiration set on every stored key
+	TTL time.Duration
+}
+
+// RedisCache - Redis based key storage used as cache
+type RedisCache struct {
+	client *redis.Client
+	prefix string
+	ttl    time.Duration
+}
+
+// Get - fetch value from key storage
+func (c *RedisCache) Get(key string) (value string, err error) {
+	logger := log.WithFields(log.Fields{"class": "RedisCache", "method": "Get"})
+
+	value, err = c.client.Get(context.Background(), c.prefix+key).Result()
+
+	switch err {
+	case nil:
+	case redis.Nil:
+		value = ""
+		err = ErrStorageKeyNotFound
+		logger.Errorf("%v", err.Error())
+	default:
+		logger.Errorf("%v", err.Error())
+		value = ""
+		err = ErrStorageConnectionFailed
+	}
+
+	return
+}
+
+// Set - save value into key storage
+func (c *RedisCache) Set(key, value string) (err error) {
+	logger := log.WithFields(log.Fields{"class": "RedisCache", "method": "Set"})
+
+	if err = c.client.Set(context.Background(), c.prefix+key, value, c.ttl).Err(); err != nil {
+		logger.Errorf("%v", err.Error())
+		err = ErrStorageConnectionFailed
+	}
+
+	return
+}
+
+// Remove - remove value by key from key storage
+func (c *RedisCache) Remove(key string) (err error) {
+	logger := log.WithFields(log.Fields{"class": "RedisCache", "method": "Remove"})
+
+	if err = c.client.Del(context.Background(), c.prefix+key).Err(); err != nil {
+		logger.Errorf("%v", err.Error())
+		err = ErrStorageConnectionFailed
+	}
+
+	return
+}
+
+// Close - release connections held by the client
+func (c *RedisCache) Close() error {
+	return c.client.Close()
+}
+
+// NewRedisCache - constructor for redis based key storage.
+// Uses sentinel failover client when options.MasterName is set and standalone client for first endpoint otherwise.
+func NewRedisCache(options RedisCacheOptions) (*RedisCache, error) {
+	if len(options.Endpoints) == 0 {
+		return nil, errors.New("at least one redis endpoint is required")
+	}
+
+	var c *redis.Client
+
+	// set timeouts to fail fast when the target endpoint is unavailable
+	if options.MasterName != "" {
+		c = redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:    options.MasterName,
+			SentinelAddrs: options.Endpoints,
+			Password:      options.Password,
+			DB:            options.DB,
+			TLSConfig:     options.TLSConfig,
+			DialTimeout:   time.Second,
+			ReadTimeout:   time.Second,
+			WriteTimeout:  time.Second,
+			MaxRetries:    1,
+		})
+	} else {
+		c = redis.NewClient(&redis.Options{
+			Addr:         options.Endpoints[0],
+			Password:     options.Password,
+			DB:           options.DB,
+			TLSConfig:    options.TLSConfig,
+			DialTimeout:  time.Second,
+			ReadTimeout:  time.Second,
+			WriteTimeout: time.Second,
+			MaxRetries:   1,
+		})
+	}
+
+	return &RedisCache{client: c, prefix: options.Prefix, ttl: options.TTL}, nil
+}
@@ -0,0 +1,162 @@
+package keyStorages
+
+import (
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Redis", func() {
+
+	const (
+		validKey        = "TestKey"
+		validValue      = "TestValue"
+		testRedisPrefix = "github-authorized-keys:tests:"
+	)
+
+	var (
+		ttl time.Duration
+	)
+
+	BeforeEach(func() {
+		ttl = 10 * time.Second
+	})
+
+	Describe("with valid connection url", func() {
+		var (
+			server *miniredis.Miniredis
+			client *RedisCache
+		)
+
+		BeforeEach(func() {
+			server = miniredis.RunT(GinkgoT())
+			client, _ = NewRedisCache(RedisCacheOptions{
+				Endpoints: []string{server.Addr()},
+				Prefix:    testRedisPrefix,
+				TTL:       ttl,
+			})
+		})
+
+		AfterEach(func() {
+			client.Close()
+			server.Close()
+		})
+
+		Describe("Set()", func() {
+			Context(" key => value", func() {
+				It("should store prefixed value with ttl", func() {
+					err := client.Set(validKey, validValue)
+
+					Expect(err).To(BeNil())
+
+					value, _ := server.Get(testRedisPrefix + validKey)
+					Expect(value).To(Equal(validValue))
+					Expect(server.TTL(testRedisPrefix + validKey)).To(Equal(ttl))
+				})
+			})
+		})
+
+		Describe("Get()", func() {
+			Context("call with existed key", func() {
+				It("should return valid value and nil error ", func() {
+					client.Set(validKey, validValue)
+					value, err := client.Get(validKey)
+					Expect(err).To(BeNil())
+					Expect(value).To(Equal(validValue))
+				})
+			})
+
+			Context("call with existed key after ttl expired", func() {
+				It("should return empty value and error ", func() {
+					client.Set(validKey, validValue)
+					server.FastForward(ttl + time.Second)
+					value, err := client.Get(validKey)
+
+					Expect(err).To(Equal(ErrStorageKeyNotFound))
+					Expect(value).To(Equal(""))
+				})
+			})
+		})
+
+		Describe("Remove()", func() {
+			Context("call with removed existed key", func() {
+				It("should return empty value and valid error ", func() {
+					client.Set(validKey, validValue)
+
+					client.Remove(validKey)
+					value, err := client.Get(validKey)
+					Expect(err).To(Equal(ErrStorageKeyNotFound))
+					Expect(value).To(Equal(""))
+				})
+			})
+		})
+
+		Describe("with password", func() {
+			BeforeEach(func() {
+				server.RequireAuth("secret")
+			})
+
+			It("should fail without valid password", func() {
+				value, err := client.Get(validKey)
+				Expect(err).To(Equal(ErrStorageConnectionFailed))
+				Expect(value).To(Equal(""))
+			})
+
+			It("should work with valid password", func() {
+				authorized, _ := NewRedisCache(RedisCacheOptions{
+					Endpoints: []string{server.Addr()},
+					Password:  "secret",
+					Prefix:    testRedisPrefix,
+					TTL:       ttl,
+				})
+				defer authorized.Close()
+
+				Expect(authorized.Set(validKey, validValue)).To(BeNil())
+				value, err := authorized.Get(validKey)
+				Expect(err).To(BeNil())
+				Expect(value).To(Equal(validValue))
+			})
+		})
+	})
+
+	Describe("with invalid connection url", func() {
+		var (
+			client *RedisCache
+		)
+
+		BeforeEach(func() {
+			client, _ = NewRedisCache(RedisCacheOptions{
+				Endpoints: []string{"127.0.0.1:1"},
+				Prefix:    testRedisPrefix,
+				TTL:       ttl,
+			})
+		})
+
+		AfterEach(func() {
+			client.Close()
+		})
+
+		It("constructor should require endpoints", func() {
+			_, err := NewRedisCache(RedisCacheOptions{})
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("Set() should return valid error", func() {
+			err := client.Set(validKey, validValue)
+			Expect(err).To(Equal(ErrStorageConnectionFailed))
+		})
+
+		It("Get() should return empty value and valid error", func() {
+			value, err := client.Get(validKey)
+			Expect(err).To(Equal(ErrStorageConnectionFailed))
+			Expect(value).To(Equal(""))
+		})
+
+		It("Remove() should return valid error", func() {
+			err := client.Remove(validKey)
+			Expect(err).To(Equal(ErrStorageConnectionFailed))
+		})
+	})
+})
@@ -0,0 +1,121 @@
+package keyStorages
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Sealed values are prefixed with format marker to tell them apart from plain ones
+const sealedValuePrefix = "gak:aes-256-gcm:v1:"
+
+// encryptionKeySize - AES-256 key size in bytes
+const encryptionKeySize = 32
+
+// ErrEncryptionKeyInvalid - returned when encryption key is not 32 bytes in raw, hex or base64 form
+var ErrEncryptionKeyInvalid = errors.New("storage: Encryption key should be 32 bytes (raw, hex or base64 encoded)")
+
+// SealedCache - fallback cache wrapper that encrypts values with AES-256-GCM.
+// Values are bound to their key, so values that are tampered, copied from another key or not encrypted are rejected.
+type SealedCache struct {
+	cache FallbackCache
+	aead  cipher.AEAD
+}
+
+// Get - fetch and decrypt value from wrapped key storage
+func (c *SealedCache) Get(key string) (string, error) {
+	logger := log.WithFields(log.Fields{"class": "SealedCache", "method": "Get"})
+
+	value, err := c.cache.Get(key)
+	if err != nil {
+		return "", err
+	}
+
+	if !strings.HasPrefix(value, sealedValuePrefix) {
+		logger.Warnf("Rejected value of %v: value is not encrypted", key)
+		return "", ErrStorageKeyNotFound
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, sealedValuePrefix))
+	if err != nil || len(sealed) < c.aead.NonceSize() {
+		logger.Warnf("Rejected value of %v: malformed encrypted value", key)
+		return "", ErrStorageKeyNotFound
+	}
+
+	nonce, ciphertext := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, ciphertext, []byte(key))
+	if err != nil {
+		logger.Warnf("Rejected value of %v: %v", key, err)
+		return "", ErrStorageKeyNotFound
+	}
+
+	return string(plaintext), nil
+}
+
+// Set - encrypt and save value into wrapped key storage
+func (c *SealedCache) Set(key, value string) error {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return err
+	}
+
+	sealed := c.aead.Seal(nonce, nonce, []byte(value), []byte(key))
+	return c.cache.Set(key, sealedValuePrefix+base64.StdEncoding.EncodeToString(sealed))
+}
+
+// Remove - remove value by key from wrapped key storage
+func (c *SealedCache) Remove(key string) error {
+	return c.cache.Remove(key)
+}
+
+// NewSealedCache - constructor for encrypting wrapper of {cache} with 32 bytes {key}
+func NewSealedCache(cache FallbackCache, key []byte) (*SealedCache, error) {
+	if len(key) != encryptionKeySize {
+		return nil, ErrEncryptionKeyInvalid
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SealedCache{cache: cache, aead: aead}, nil
+}
+
+// LoadEncryptionKey - read 32 bytes key from file, the key could be stored raw, hex or base64 encoded
+// (e.g. generated with `openssl rand -hex 32`)
+func LoadEncryptionKey(path string) ([]byte, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(content) == encryptionKeySize {
+		return content, nil
+	}
+
+	encoded := strings.TrimSpace(string(content))
+
+	if key, err := hex.DecodeString(encoded); err == nil && len(key) == encryptionKeySize {
+		return key, nil
+	}
+
+	if key, err := base64.StdEncoding.DecodeString(encoded); err == nil && len(key) == encryptionKeySize {
+		return key, nil
+	}
+
+	return nil, ErrEncryptionKeyInvalid
+}
@@ -0,0 +1,136 @@
+package keyStorages
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SealedCache", func() {
+	const (
+		validKey   = "TestKey"
+		validValue = "TestValue"
+	)
+
+	var (
+		encryptionKey []byte
+		cacheStorage  map[string]string
+		client        *SealedCache
+	)
+
+	BeforeEach(func() {
+		encryptionKey = []byte(strings.Repeat("k", encryptionKeySize))
+		cacheStorage = map[string]string{}
+		client, _ = NewSealedCache(&CacheMap{storage: &cacheStorage}, encryptionKey)
+	})
+
+	Describe("constructor NewSealedCache()", func() {
+		It("should reject key of invalid size", func() {
+			_, err := NewSealedCache(&CacheMap{storage: &cacheStorage}, []byte("short"))
+			Expect(err).To(Equal(ErrEncryptionKeyInvalid))
+		})
+	})
+
+	Describe("Set()", func() {
+		It("should not store plain value", func() {
+			err := client.Set(validKey, validValue)
+
+			Expect(err).To(BeNil())
+			Expect(cacheStorage[validKey]).To(HavePrefix(sealedValuePrefix))
+			Expect(cacheStorage[validKey]).NotTo(ContainSubstring(validValue))
+		})
+	})
+
+	Describe("Get()", func() {
+		It("should return decrypted value", func() {
+			client.Set(validKey, validValue)
+
+			value, err := client.Get(validKey)
+
+			Expect(err).To(BeNil())
+			Expect(value).To(Equal(validValue))
+		})
+
+		It("should reject plain value", func() {
+			cacheStorage[validKey] = validValue
+
+			value, err := client.Get(validKey)
+
+			Expect(err).To(Equal(ErrStorageKeyNotFound))
+			Expect(value).To(Equal(""))
+		})
+
+		It("should reject value copied from another key", func() {
+			client.Set("AnotherKey", validValue)
+			cacheStorage[validKey] = cacheStorage["AnotherKey"]
+
+			value, err := client.Get(validKey)
+
+			Expect(err).To(Equal(ErrStorageKeyNotFound))
+			Expect(value).To(Equal(""))
+		})
+
+		It("should reject value encrypted with another key", func() {
+			foreign, _ := NewSealedCache(&CacheMap{storage: &cacheStorage}, []byte(strings.Repeat("f", encryptionKeySize)))
+			foreign.Set(validKey, validValue)
+
+			value, err := client.Get(validKey)
+
+			Expect(err).To(Equal(ErrStorageKeyNotFound))
+			Expect(value).To(Equal(""))
+		})
+
+		It("should pass through errors of wrapped storage", func() {
+			value, err := client.Get(validKey)
+
+			Expect(err).To(Equal(ErrStorageKeyNotFound))
+			Expect(value).To(Equal(""))
+		})
+	})
+
+	Describe("LoadEncryptionKey()", func() {
+		var dir string
+
+		BeforeEach(func() {
+			dir, _ = ioutil.TempDir("", "sealed")
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("should read hex encoded key", func() {
+			path := filepath.Join(dir, "key")
+			ioutil.WriteFile(path, []byte(hex.EncodeToString(encryptionKey)+"\n"), 0600)
+
+			key, err := LoadEncryptionKey(path)
+
+			Expect(err).To(BeNil())
+			Expect(key).To(Equal(encryptionKey))
+		})
+
+		It("should read raw key", func() {
+			path := filepath.Join(dir, "key")
+			ioutil.WriteFile(path, encryptionKey, 0600)
+
+			key, err := LoadEncryptionKey(path)
+
+			Expect(err).To(BeNil())
+			Expect(key).To(Equal(encryptionKey))
+		})
+
+		It("should reject key of invalid size", func() {
+			path := filepath.Join(dir, "key")
+			ioutil.WriteFile(path, []byte("deadbeef"), 0600)
+
+			_, err := LoadEncryptionKey(path)
+
+			Expect(err).To(Equal(ErrEncryptionKeyInvalid))
+		})
+	})
+})
@@ -0,0 +1,204 @@
+package keyStorages
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Signed values have format gak:sig:v1:{algorithm}:{base64 signature}:{value}
+const signedValuePrefix = "gak:sig:v1:"
+
+const (
+	// SigningAlgorithmHMAC - shared secret HMAC-SHA256 signatures
+	SigningAlgorithmHMAC = "hmac-sha256"
+
+	// SigningAlgorithmEd25519 - Ed25519 signatures, readers could hold only public key
+	SigningAlgorithmEd25519 = "ed25519"
+)
+
+// hmacKeyMinSize - minimal length of HMAC shared secret in bytes
+const hmacKeyMinSize = 32
+
+var (
+	// ErrSigningKeyMissing - returned on write when only verification key is configured
+	ErrSigningKeyMissing = errors.New("storage: Signing key is not configured")
+
+	// ErrSigningKeyInvalid - returned when signing or verification key could not be loaded
+	ErrSigningKeyInvalid = errors.New("storage: Signing key should be Ed25519 PEM key or HMAC secret of at least 32 bytes")
+)
+
+// SigningKeys - keys used to sign and verify cache values
+type SigningKeys struct {
+	algorithm  string
+	hmacKey    []byte
+	privateKey ed25519.PrivateKey
+	publicKey  ed25519.PublicKey
+}
+
+// Algorithm - return signature algorithm
+func (k *SigningKeys) Algorithm() string {
+	return k.algorithm
+}
+
+// CanSign - check if keys contain signing key
+func (k *SigningKeys) CanSign() bool {
+	return k.hmacKey != nil || k.privateKey != nil
+}
+
+func (k *SigningKeys) sign(message []byte) []byte {
+	if k.algorithm == SigningAlgorithmHMAC {
+		mac := hmac.New(sha256.New, k.hmacKey)
+		mac.Write(message)
+		return mac.Sum(nil)
+	}
+	return ed25519.Sign(k.privateKey, message)
+}
+
+func (k *SigningKeys) verify(message, signature []byte) bool {
+	if k.algorithm == SigningAlgorithmHMAC {
+		return hmac.Equal(k.sign(message), signature)
+	}
+	return ed25519.Verify(k.publicKey, message, signature)
+}
+
+// SignedCache - fallback cache wrapper that signs values on write and returns only values with valid signature.
+// Signature covers the key, so values copied from another key are rejected as well.
+type SignedCache struct {
+	cache FallbackCache
+	keys  *SigningKeys
+}
+
+// Get - fetch value from wrapped key storage and verify its signature
+func (c *SignedCache) Get(key string) (string, error) {
+	logger := log.WithFields(log.Fields{"class": "SignedCache", "method": "Get"})
+
+	value, err := c.cache.Get(key)
+	if err != nil {
+		return "", err
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(value, signedValuePrefix), ":", 3)
+	if !strings.HasPrefix(value, signedValuePrefix) || len(parts) != 3 {
+		logger.Warnf("Rejected value of %v: value is not signed", key)
+		return "", ErrStorageKeyNotFound
+	}
+
+	algorithm, payload := parts[0], parts[2]
+	signature, err := base64.StdEncoding.DecodeString(parts[1])
+
+	if err != nil || algorithm != c.keys.algorithm || !c.keys.verify(signedMessage(key, payload), signature) {
+		logger.Warnf("Rejected value of %v: invalid signature", key)
+		return "", ErrStorageKeyNotFound
+	}
+
+	return payload, nil
+}
+
+// Set - sign and save value into wrapped key storage
+func (c *SignedCache) Set(key, value string) error {
+	if !c.keys.CanSign() {
+		return ErrSigningKeyMissing
+	}
+
+	signature := base64.StdEncoding.EncodeToString(c.keys.sign(signedMessage(key, value)))
+	return c.cache.Set(key, signedValuePrefix+c.keys.algorithm+":"+signature+":"+value)
+}
+
+// Remove - remove value by key from wrapped key storage
+func (c *SignedCache) Remove(key string) error {
+	return c.cache.Remove(key)
+}
+
+func signedMessage(key, value string) []byte {
+	return []byte(key + "\n" + value)
+}
+
+// NewSignedCache - constructor for signing wrapper of {cache}
+func NewSignedCache(cache FallbackCache, keys *SigningKeys) *SignedCache {
+	return &SignedCache{cache: cache, keys: keys}
+}
+
+// LoadSigningKeys - read signing and verification keys from files.
+//
+// signingKeyFile - Ed25519 private key in PKCS #8 PEM format or HMAC shared secret. Could be empty on hosts that only read cache.
+//
+// verifyKeyFile - Ed25519 public key in PKIX PEM format or HMAC shared secret. Could be empty when signing key is set.
+func LoadSigningKeys(signingKeyFile, verifyKeyFile string) (*SigningKeys, error) {
+	keys := &SigningKeys{}
+
+	if signingKeyFile != "" {
+		if err := keys.load(signingKeyFile, "PRIVATE KEY"); err != nil {
+			return nil, err
+		}
+	}
+
+	if verifyKeyFile != "" {
+		if err := keys.load(verifyKeyFile, "PUBLIC KEY"); err != nil {
+			return nil, err
+		}
+	}
+
+	if keys.algorithm == "" {
+		return nil, ErrSigningKeyInvalid
+	}
+
+	return keys, nil
+}
+
+func (k *SigningKeys) load(path, pemType string) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	algorithm := SigningAlgorithmHMAC
+	block, _ := pem.Decode(content)
+	if block != nil {
+		algorithm = SigningAlgorithmEd25519
+	}
+
+	if k.algorithm != "" && k.algorithm != algorithm {
+		return errors.New("storage: Signing and verification keys use different algorithms")
+	}
+	k.algorithm = algorithm
+
+	switch {
+	case block == nil:
+		secret := []byte(strings.TrimSpace(string(content)))
+		if len(secret) < hmacKeyMinSize {
+			return ErrSigningKeyInvalid
+		}
+		k.hmacKey = secret
+
+	case block.Type != pemType:
+		return ErrSigningKeyInvalid
+
+	case pemType == "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		privateKey, ok := key.(ed25519.PrivateKey)
+		if err != nil || !ok {
+			return ErrSigningKeyInvalid
+		}
+		k.privateKey = privateKey
+		k.publicKey = privateKey.Public().(ed25519.PublicKey)
+
+	default:
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		publicKey, ok := key.(ed25519.PublicKey)
+		if err != nil || !ok {
+			return ErrSigningKeyInvalid
+		}
+		k.publicKey = publicKey
+	}
+
+	return nil
+}
@@ -0,0 +1,169 @@
+package keyStorages
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SignedCache", func() {
+	const (
+		validKey   = "TestKey"
+		validValue = "TestValue"
+	)
+
+	var (
+		dir            string
+		privateKeyFile string
+		publicKeyFile  string
+		hmacKeyFile    string
+		cacheStorage   map[string]string
+	)
+
+	writePEM := func(path, pemType string, der []byte) {
+		ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: pemType, Bytes: der}), 0600)
+	}
+
+	BeforeEach(func() {
+		dir, _ = ioutil.TempDir("", "signed")
+		cacheStorage = map[string]string{}
+
+		publicKey, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+		privateDER, _ := x509.MarshalPKCS8PrivateKey(privateKey)
+		publicDER, _ := x509.MarshalPKIXPublicKey(publicKey)
+
+		privateKeyFile = filepath.Join(dir, "signing.pem")
+		publicKeyFile = filepath.Join(dir, "verify.pem")
+		hmacKeyFile = filepath.Join(dir, "hmac")
+
+		writePEM(privateKeyFile, "PRIVATE KEY", privateDER)
+		writePEM(publicKeyFile, "PUBLIC KEY", publicDER)
+		ioutil.WriteFile(hmacKeyFile, []byte(strings.Repeat("s", hmacKeyMinSize)+"\n"), 0600)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	Describe("LoadSigningKeys()", func() {
+		It("should detect Ed25519 keys", func() {
+			keys, err := LoadSigningKeys(privateKeyFile, "")
+
+			Expect(err).To(BeNil())
+			Expect(keys.Algorithm()).To(Equal(SigningAlgorithmEd25519))
+			Expect(keys.CanSign()).To(BeTrue())
+		})
+
+		It("should load verification only Ed25519 key", func() {
+			keys, err := LoadSigningKeys("", publicKeyFile)
+
+			Expect(err).To(BeNil())
+			Expect(keys.CanSign()).To(BeFalse())
+		})
+
+		It("should detect HMAC secret", func() {
+			keys, err := LoadSigningKeys(hmacKeyFile, "")
+
+			Expect(err).To(BeNil())
+			Expect(keys.Algorithm()).To(Equal(SigningAlgorithmHMAC))
+		})
+
+		It("should reject keys of different algorithms", func() {
+			_, err := LoadSigningKeys(privateKeyFile, hmacKeyFile)
+
+			Expect(err).NotTo(BeNil())
+		})
+
+		It("should reject public key as signing key", func() {
+			_, err := LoadSigningKeys(publicKeyFile, "")
+
+			Expect(err).To(Equal(ErrSigningKeyInvalid))
+		})
+
+		It("should reject short HMAC secret", func() {
+			ioutil.WriteFile(hmacKeyFile, []byte("short"), 0600)
+
+			_, err := LoadSigningKeys(hmacKeyFile, "")
+
+			Expect(err).To(Equal(ErrSigningKeyInvalid))
+		})
+	})
+
+	for _, algorithm := range []string{SigningAlgorithmEd25519, SigningAlgorithmHMAC} {
+		algorithm := algorithm
+
+		Describe("with "+algorithm+" keys", func() {
+			var (
+				writer *SignedCache
+				reader *SignedCache
+			)
+
+			BeforeEach(func() {
+				var writerKeys, readerKeys *SigningKeys
+				if algorithm == SigningAlgorithmEd25519 {
+					writerKeys, _ = LoadSigningKeys(privateKeyFile, "")
+					readerKeys, _ = LoadSigningKeys("", publicKeyFile)
+				} else {
+					writerKeys, _ = LoadSigningKeys(hmacKeyFile, "")
+					readerKeys, _ = LoadSigningKeys("", hmacKeyFile)
+				}
+				writer = NewSignedCache(&CacheMap{storage: &cacheStorage}, writerKeys)
+				reader = NewSignedCache(&CacheMap{storage: &cacheStorage}, readerKeys)
+			})
+
+			It("should return value signed by writer", func() {
+				Expect(writer.Set(validKey, validValue)).To(BeNil())
+
+				value, err := reader.Get(validKey)
+
+				Expect(err).To(BeNil())
+				Expect(value).To(Equal(validValue))
+			})
+
+			It("should reject unsigned value", func() {
+				cacheStorage[validKey] = validValue
+
+				value, err := reader.Get(validKey)
+
+				Expect(err).To(Equal(ErrStorageKeyNotFound))
+				Expect(value).To(Equal(""))
+			})
+
+			It("should reject tampered value", func() {
+				writer.Set(validKey, validValue)
+				cacheStorage[validKey] = cacheStorage[validKey] + "\nssh-ed25519 AAAA injected"
+
+				value, err := reader.Get(validKey)
+
+				Expect(err).To(Equal(ErrStorageKeyNotFound))
+				Expect(value).To(Equal(""))
+			})
+
+			It("should reject value copied from another key", func() {
+				writer.Set("AnotherKey", validValue)
+				cacheStorage[validKey] = cacheStorage["AnotherKey"]
+
+				value, err := reader.Get(validKey)
+
+				Expect(err).To(Equal(ErrStorageKeyNotFound))
+				Expect(value).To(Equal(""))
+			})
+		})
+	}
+
+	It("should refuse to write without signing key", func() {
+		keys, _ := LoadSigningKeys("", publicKeyFile)
+		reader := NewSignedCache(&CacheMap{storage: &cacheStorage}, keys)
+
+		Expect(reader.Set(validKey, validValue)).To(Equal(ErrSigningKeyMissing))
+		Expect(cacheStorage).To(BeEmpty())
+	})
+})
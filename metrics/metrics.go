@@ -0,0 +1,109 @@
+/*
+ * Github Authorized Keys - Use GitHub teams to manage system user accounts and authorized_keys
+ *
+ * Copyright 2016 Cloud Posse, LLC <hello@cloudposse.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "github_authorized_keys"
+
+// Outcomes of authorized_keys requests
+const (
+	// OutcomeHit - keys fetched from github.com
+	OutcomeHit = "hit"
+	// OutcomeMiss - user or keys not found
+	OutcomeMiss = "miss"
+	// OutcomeDenied - user is not a team member or cached keys are too stale
+	OutcomeDenied = "denied"
+	// OutcomeFallback - keys served from fallback cache
+	OutcomeFallback = "fallback"
+	// OutcomeError - neither source nor cache could answer
+	OutcomeError = "error"
+	// OutcomeRejected - invalid username or client exceeded its request rate
+	OutcomeRejected = "rejected"
+)
+
+var (
+	// AuthorizedKeysRequests - count of authorized_keys requests by outcome
+	AuthorizedKeysRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "authorized_keys_requests_total",
+		Help:      "Count of authorized_keys requests by outcome.",
+	}, []string{"outcome"})
+
+	// AuthorizedKeysDuration - latency of authorized_keys requests
+	AuthorizedKeysDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "authorized_keys_request_duration_seconds",
+		Help:      "Latency of authorized_keys requests.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	// CacheLookups - count of fallback cache lookups by result
+	CacheLookups = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "cache_lookups_total",
+		Help:      "Count of fallback cache lookups by result (hit or miss).",
+	}, []string{"result"})
+
+	// GithubAPICalls - count of GitHub API calls by endpoint and HTTP status
+	GithubAPICalls = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "github_api_calls_total",
+		Help:      "Count of GitHub API calls by endpoint and HTTP status, status is \"error\" when no response was received.",
+	}, []string{"endpoint", "status"})
+
+	// GithubRateLimitRemaining - GitHub API requests remaining in current rate limit window
+	GithubRateLimitRemaining = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "github_rate_limit_remaining",
+		Help:      "GitHub API requests remaining in current rate limit window, as reported by last response.",
+	})
+
+	// SyncDuration - duration of users synchronization runs
+	SyncDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "sync_users_duration_seconds",
+		Help:      "Duration of users synchronization runs.",
+		Buckets:   []float64{0.5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+	})
+
+	// SyncFailures - count of failed users synchronization runs
+	SyncFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "sync_users_failures_total",
+		Help:      "Count of failed users synchronization runs.",
+	})
+
+	// UsersCreated - count of created linux users
+	UsersCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "users_created_total",
+		Help:      "Count of created linux users.",
+	})
+
+	// UsersRemoved - count of linux users removed or retired by rename after GitHub login change
+	UsersRemoved = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "users_removed_total",
+		Help:      "Count of removed linux users, including accounts retired by rename after GitHub login change.",
+	})
+)
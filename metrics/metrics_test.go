@@ -0,0 +1,53 @@
+/*
+ * Github Authorized Keys - Use GitHub teams to manage system user accounts and authorized_keys
+ *
+ * Copyright 2016 Cloud Posse, LLC <hello@cloudposse.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package metrics
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var _ = Describe("Metrics", func() {
+	scrape := func() string {
+		recorder := httptest.NewRecorder()
+		promhttp.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+		Expect(recorder.Code).To(Equal(200))
+		body, _ := ioutil.ReadAll(recorder.Body)
+		return string(body)
+	}
+
+	It("should expose lookup outcomes and sync counters", func() {
+		AuthorizedKeysRequests.WithLabelValues(OutcomeDenied).Inc()
+		SyncFailures.Inc()
+		SyncDuration.Observe(1.5)
+		UsersCreated.Inc()
+		UsersRemoved.Inc()
+
+		body := scrape()
+		Expect(body).To(ContainSubstring(`github_authorized_keys_authorized_keys_requests_total{outcome="denied"} 1`))
+		Expect(body).To(ContainSubstring("github_authorized_keys_sync_users_failures_total 1"))
+		Expect(body).To(ContainSubstring("github_authorized_keys_sync_users_duration_seconds_count 1"))
+		Expect(body).To(ContainSubstring("github_authorized_keys_users_created_total 1"))
+		Expect(body).To(ContainSubstring("github_authorized_keys_users_removed_total 1"))
+	})
+})
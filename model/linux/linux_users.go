@@ -23,9 +23,11 @@ import "strings"
 // User - struct that extends os/user struct with shell param
 type User struct {
 	name   string
+	uid    string // empty to let the system pick uid
 	gid    string // primary group ID
 	groups []string
 	shell  string
+	gecos  string // comment field, e.g. full name
 }
 
 // NewUser - creates new User
@@ -38,6 +40,16 @@ func (user *User) Name() string {
 	return strings.ToLower(user.name)
 }
 
+// Uid - return user uid, empty if it is picked by the system
+func (user *User) Uid() string {
+	return user.uid
+}
+
+// SetUid - set user uid, personal group of the user gets the same gid
+func (user *User) SetUid(uid string) {
+	user.uid = uid
+}
+
 // Gid - return user gid
 func (user *User) Gid() string {
 	return user.gid
@@ -48,6 +60,16 @@ func (user *User) Groups() []string {
 	return user.groups
 }
 
+// Gecos - return user comment field
+func (user *User) Gecos() string {
+	return user.gecos
+}
+
+// SetGecos - set user comment field
+func (user *User) SetGecos(gecos string) {
+	user.gecos = gecos
+}
+
 // Shell - return user shell
 func (user *User) Shell() string {
 	return user.shell
@@ -0,0 +1,141 @@
+/*
+ * Github Authorized Keys - Use GitHub teams to manage system user accounts and authorized_keys
+ *
+ * Copyright 2016 Cloud Posse, LLC <hello@cloudposse.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package linux
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// hashLength - count of hex digits of login hash appended to truncated names
+const hashLength = 6
+
+// Substitution - replace {From} with {To} in linux user names
+type Substitution struct {
+	From string
+	To   string
+}
+
+// Mapping - rules to derive linux user names from GitHub logins
+type Mapping struct {
+	// Overrides - linux user names of GitHub logins, used as is instead of other rules
+	Overrides map[string]string
+
+	Substitutions []Substitution
+	Prefix        string
+	Suffix        string
+
+	// MaxLength - longer names are truncated and suffixed with login hash, 0 disables truncation
+	MaxLength int
+}
+
+// NewMapping - create mapping from {overrides} of logins to names and {substitutions} applied in order
+func NewMapping(overrides map[string]string, substitutions []Substitution, prefix, suffix string, maxLength int) Mapping {
+	mapping := Mapping{Overrides: map[string]string{}, Substitutions: substitutions, Prefix: prefix, Suffix: suffix, MaxLength: maxLength}
+	for login, name := range overrides {
+		mapping.Overrides[strings.ToLower(login)] = strings.ToLower(name)
+	}
+	return mapping
+}
+
+// Validate - check that truncated names keep room for prefix, suffix and hash, and overrides are unique
+func (m Mapping) Validate() error {
+	if m.MaxLength < 0 {
+		return fmt.Errorf("user name max length could not be negative")
+	}
+	if m.MaxLength > 0 && m.MaxLength <= len(m.Prefix)+len(m.Suffix)+hashLength {
+		return fmt.Errorf("user name max length should be greater than %v to fit prefix, suffix and hash", len(m.Prefix)+len(m.Suffix)+hashLength)
+	}
+
+	for _, substitution := range m.Substitutions {
+		if substitution.From == "" {
+			return fmt.Errorf("user name substitution of empty string")
+		}
+	}
+
+	names := map[string]string{}
+	for login, name := range m.Overrides {
+		if login == "" {
+			return fmt.Errorf("user name override of empty login")
+		}
+		if name == "" {
+			return fmt.Errorf("user name override of %v is empty", login)
+		}
+		if other, ok := names[name]; ok {
+			return fmt.Errorf("GitHub logins %v and %v are mapped to the same user name %v", other, login, name)
+		}
+		names[name] = login
+	}
+	return nil
+}
+
+// LinuxName - return linux user name of GitHub {login}
+func (m Mapping) LinuxName(login string) string {
+	login = strings.ToLower(login)
+	if name, ok := m.Overrides[login]; ok {
+		return name
+	}
+
+	name := login
+	for _, substitution := range m.Substitutions {
+		name = strings.ReplaceAll(name, substitution.From, substitution.To)
+	}
+
+	if m.MaxLength > 0 && len(m.Prefix)+len(name)+len(m.Suffix) > m.MaxLength {
+		// Hash keeps truncated names of logins with the same beginning apart
+		sum := sha256.Sum256([]byte(login))
+		keep := m.MaxLength - len(m.Prefix) - len(m.Suffix) - hashLength
+		name = name[:keep] + hex.EncodeToString(sum[:])[:hashLength]
+	}
+
+	return m.Prefix + name + m.Suffix
+}
+
+// Reverse - return GitHub login that is mapped to linux user {name} by rules other than overrides
+func (m Mapping) Reverse(name string) (string, bool) {
+	if !strings.HasPrefix(name, m.Prefix) || !strings.HasSuffix(name, m.Suffix) || len(name) < len(m.Prefix)+len(m.Suffix) {
+		return "", false
+	}
+	login := name[len(m.Prefix) : len(name)-len(m.Suffix)]
+
+	for i := len(m.Substitutions) - 1; i >= 0; i-- {
+		if m.Substitutions[i].To != "" {
+			login = strings.ReplaceAll(login, m.Substitutions[i].To, m.Substitutions[i].From)
+		}
+	}
+
+	// Truncated names and names of overridden logins could not be reversed
+	if _, overridden := m.Overrides[login]; overridden || login == "" || m.LinuxName(login) != name {
+		return "", false
+	}
+	return login, true
+}
+
+// OverriddenLogin - return GitHub login overridden to linux user {name}
+func (m Mapping) OverriddenLogin(name string) (string, bool) {
+	name = strings.ToLower(name)
+	for login, override := range m.Overrides {
+		if override == name {
+			return login, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,87 @@
+/*
+ * Github Authorized Keys - Use GitHub teams to manage system user accounts and authorized_keys
+ *
+ * Copyright 2016 Cloud Posse, LLC <hello@cloudposse.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package linux
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Mapping", func() {
+	var mapping Mapping
+
+	BeforeEach(func() {
+		mapping = NewMapping(map[string]string{"Boss": "admin"}, []Substitution{{From: "-", To: "_"}}, "gh_", "", 16)
+		Expect(mapping.Validate()).To(BeNil())
+	})
+
+	DescribeTable("LinuxName()",
+		func(login, name string) {
+			Expect(mapping.LinuxName(login)).To(Equal(name))
+		},
+		Entry("lowercases login", "Goruha", "gh_goruha"),
+		Entry("substitutes characters", "cloud-posse", "gh_cloud_posse"),
+		Entry("uses override as is", "boss", "admin"),
+		Entry("truncates long login and appends hash", "a-very-long-github-login", "gh_a_very_bc3c4e"),
+	)
+
+	Describe("Reverse()", func() {
+		It("should reverse mapping rules", func() {
+			login, ok := mapping.Reverse("gh_cloud_posse")
+			Expect(ok).To(BeTrue())
+			Expect(login).To(Equal("cloud-posse"))
+		})
+
+		It("should not map names without prefix or names of overridden logins", func() {
+			_, ok := mapping.Reverse("root")
+			Expect(ok).To(BeFalse())
+			_, ok = mapping.Reverse("gh_boss")
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Describe("OverriddenLogin()", func() {
+		It("should return login of overridden name", func() {
+			login, ok := mapping.OverriddenLogin("Admin")
+			Expect(ok).To(BeTrue())
+			Expect(login).To(Equal("boss"))
+		})
+	})
+
+	Describe("Validate()", func() {
+		It("should reject max length without room for hash", func() {
+			Expect(NewMapping(nil, nil, "gh_", "", 9).Validate()).NotTo(BeNil())
+		})
+
+		It("should reject overrides mapped to the same name", func() {
+			Expect(NewMapping(map[string]string{"alice": "ops", "bob": "ops"}, nil, "", "", 0).Validate()).NotTo(BeNil())
+		})
+
+		It("should reject substitution of empty string", func() {
+			Expect(NewMapping(nil, []Substitution{{From: "", To: "_"}}, "", "", 0).Validate()).NotTo(BeNil())
+		})
+
+		It("should accept separators in substitutions", func() {
+			mapping := NewMapping(nil, []Substitution{{From: "=", To: ","}, {From: ",", To: "-"}}, "", "", 0)
+			Expect(mapping.Validate()).To(BeNil())
+			Expect(mapping.LinuxName("a=b,c")).To(Equal("a-b-c"))
+		})
+	})
+})
@@ -0,0 +1,77 @@
+/*
+ * Github Authorized Keys - Use GitHub teams to manage system user accounts and authorized_keys
+ *
+ * Copyright 2016 Cloud Posse, LLC <hello@cloudposse.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package linux
+
+import "sort"
+
+const (
+	// UserBackendCommand - manage users with command templates (adduser, useradd, ...)
+	UserBackendCommand = "command"
+
+	// UserBackendNative - manage users by editing passwd, shadow, group and gshadow files directly
+	UserBackendNative = "native"
+)
+
+const (
+	// UIDCollisionProbe - on collision try following ids of the range
+	UIDCollisionProbe = "probe"
+
+	// UIDCollisionAuto - on collision let the user backend pick any free id
+	UIDCollisionAuto = "auto"
+
+	// UIDCollisionSkip - on collision do not create the user
+	UIDCollisionSkip = "skip"
+)
+
+// Policies applied when GitHub login of managed account changes
+const (
+	// RenamePolicyRename - rename linux account and move its home directory
+	RenamePolicyRename = "rename"
+	// RenamePolicyAlias - keep linux account name, keys are looked up by the new GitHub login
+	RenamePolicyAlias = "alias"
+	// RenamePolicyNone - do not track renames, new linux account is created for the new login
+	RenamePolicyNone = "none"
+)
+
+const (
+	// ProfileAuto - detect profile from os-release
+	ProfileAuto = "auto"
+
+	// ProfileDebian - Debian, Ubuntu and derivatives (adduser)
+	ProfileDebian = "debian"
+
+	// ProfileRHEL - RHEL, Fedora, CentOS, Rocky, Alma, Amazon Linux (useradd)
+	ProfileRHEL = "rhel"
+
+	// ProfileAlpine - Alpine and other BusyBox based systems
+	ProfileAlpine = "alpine"
+
+	// ProfileArch - Arch Linux and derivatives
+	ProfileArch = "arch"
+
+	// ProfileSUSE - openSUSE and SLES
+	ProfileSUSE = "suse"
+)
+
+// ProfileNames - return names of built-in profiles
+func ProfileNames() []string {
+	names := []string{ProfileDebian, ProfileRHEL, ProfileAlpine, ProfileArch, ProfileSUSE}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,179 @@
+/*
+ * Github Authorized Keys - Use GitHub teams to manage system user accounts and authorized_keys
+ *
+ * Copyright 2016 Cloud Posse, LLC <hello@cloudposse.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/go-github/v43/github"
+	"github.com/terjekv/github-authorized-keys/api"
+	"github.com/terjekv/github-authorized-keys/config"
+	"github.com/terjekv/github-authorized-keys/jobs"
+	keyStorages "github.com/terjekv/github-authorized-keys/key_storages"
+)
+
+// Key used to probe cache, it is never written
+const cacheProbeKey = "__github-authorized-keys-probe__"
+
+// Sync is considered recent if it succeeded within this count of sync intervals
+const syncIntervalsTolerance = 3
+
+type cacheStatus struct {
+	Backend   string `json:"backend"`
+	Reachable bool   `json:"reachable"`
+}
+
+type githubStatus struct {
+	Reachable          bool       `json:"reachable"`
+	RateLimit          int        `json:"rate_limit"`
+	RateLimitRemaining int        `json:"rate_limit_remaining"`
+	RateLimitReset     *time.Time `json:"rate_limit_reset,omitempty"`
+	Error              string     `json:"error,omitempty"`
+}
+
+type syncStatus struct {
+	jobs.SyncStatus
+	// Recent - last sync succeeded within syncIntervalsTolerance sync intervals
+	Recent bool `json:"recent"`
+}
+
+type status struct {
+	Github githubStatus `json:"github"`
+	Cache  cacheStatus  `json:"cache"`
+	Sync   syncStatus   `json:"sync"`
+}
+
+// rateLimitClient - GitHub client reporting rate limit of its token
+type rateLimitClient interface {
+	RateLimit() (*github.Rate, error)
+}
+
+func healthz(c *gin.Context) {
+	c.JSON(200, gin.H{"status": "ok"})
+}
+
+// statusClient - GitHub client used by status, recreated only when token is rotated or organization is changed by config reload
+type statusClient struct {
+	mutex        sync.Mutex
+	token        string
+	organization string
+	client       rateLimitClient
+
+	// newClient - create client, api.NewGithubClient if not set
+	newClient func(token, organization string) rateLimitClient
+}
+
+func (s *statusClient) get(cfg config.Config) rateLimitClient {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	token := cfg.GithubToken()
+	if s.client == nil || s.token != token || s.organization != cfg.GithubOrganization {
+		if s.newClient != nil {
+			s.client = s.newClient(token, cfg.GithubOrganization)
+		} else {
+			s.client = api.NewGithubClient(token, cfg.GithubOrganization)
+		}
+		s.token, s.organization = token, cfg.GithubOrganization
+	}
+	return s.client
+}
+
+// readyz - ready while keys could be served, that is the listener answers and the cache is reachable.
+// GitHub outages and late syncs are served from the cache, so they are reported by status only.
+func readyz(holder *config.Holder, cache keyStorages.FallbackCache) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		checks := map[string]bool{
+			"cache": checkCache(holder.Load(), cache).Reachable,
+		}
+
+		code := 200
+		for _, passed := range checks {
+			if !passed {
+				code = 503
+			}
+		}
+
+		c.JSON(code, checks)
+	}
+}
+
+func statusz(holder *config.Holder, probes *statusClient, cache keyStorages.FallbackCache) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := holder.Load()
+		c.JSON(200, collectStatus(cfg, probes.get(cfg), cache))
+	}
+}
+
+func collectStatus(cfg config.Config, client rateLimitClient, cache keyStorages.FallbackCache) status {
+	last := jobs.LastSync()
+	return status{
+		Github: checkGithub(client),
+		Cache:  checkCache(cfg, cache),
+		Sync:   syncStatus{SyncStatus: last, Recent: syncIsRecent(cfg, last)},
+	}
+}
+
+func checkGithub(client rateLimitClient) githubStatus {
+	rate, err := client.RateLimit()
+	if err != nil {
+		return githubStatus{Reachable: false, Error: err.Error()}
+	}
+
+	return githubStatus{
+		Reachable:          true,
+		RateLimit:          rate.Limit,
+		RateLimitRemaining: rate.Remaining,
+		RateLimitReset:     &rate.Reset.Time,
+	}
+}
+
+func checkCache(cfg config.Config, cache keyStorages.FallbackCache) cacheStatus {
+	backend := "none"
+	switch {
+	case len(cfg.RedisEndpoints) > 0:
+		backend = "redis"
+	case len(cfg.EtcdEndpoints) > 0:
+		backend = "etcd"
+	}
+
+	// Disabled cache never fails lookups
+	if _, ok := cache.(*keyStorages.NilStorage); ok {
+		return cacheStatus{Backend: "none", Reachable: true}
+	}
+
+	_, err := cache.Get(cacheProbeKey)
+	return cacheStatus{Backend: backend, Reachable: err != keyStorages.ErrStorageConnectionFailed}
+}
+
+func syncIsRecent(cfg config.Config, sync jobs.SyncStatus) bool {
+	if sync.LastSuccess.IsZero() {
+		return false
+	}
+
+	// Without scheduler only sync on start is done
+	if cfg.Interval == 0 {
+		return true
+	}
+
+	tolerance := time.Duration(cfg.Interval*syncIntervalsTolerance) * time.Second
+	return time.Since(sync.LastSuccess) <= tolerance
+}
@@ -0,0 +1,122 @@
+/*
+ * Github Authorized Keys - Use GitHub teams to manage system user accounts and authorized_keys
+ *
+ * Copyright 2016 Cloud Posse, LLC <hello@cloudposse.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/go-github/v43/github"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/terjekv/github-authorized-keys/config"
+	keyStorages "github.com/terjekv/github-authorized-keys/key_storages"
+)
+
+// fakeRateLimit - GitHub client reporting {rate} or failing with {err}
+type fakeRateLimit struct {
+	rate *github.Rate
+	err  error
+}
+
+func (c *fakeRateLimit) RateLimit() (*github.Rate, error) {
+	return c.rate, c.err
+}
+
+// fakeCache - fallback cache failing every call with {err}
+type fakeCache struct {
+	err error
+}
+
+func (c *fakeCache) Get(key string) (string, error) { return "", c.err }
+func (c *fakeCache) Set(key, value string) error    { return c.err }
+func (c *fakeCache) Remove(key string) error        { return c.err }
+
+var _ = Describe("Health", func() {
+	var (
+		githubClient *fakeRateLimit
+		cache        keyStorages.FallbackCache
+	)
+
+	request := func(path string) (int, map[string]interface{}) {
+		holder := config.NewHolder(config.Config{Interval: 60, RedisEndpoints: []string{"localhost:6379"}})
+		probes := &statusClient{newClient: func(token, organization string) rateLimitClient { return githubClient }}
+
+		router := gin.New()
+		router.GET("/healthz", healthz)
+		router.GET("/readyz", readyz(holder, cache))
+		router.GET("/status", statusz(holder, probes, cache))
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+
+		body := map[string]interface{}{}
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &body)).To(BeNil())
+		return recorder.Code, body
+	}
+
+	BeforeEach(func() {
+		githubClient = &fakeRateLimit{err: errors.New("connection refused")}
+		cache = &fakeCache{err: keyStorages.ErrStorageKeyNotFound}
+	})
+
+	Describe("/healthz", func() {
+		It("should be ok", func() {
+			code, body := request("/healthz")
+			Expect(code).To(Equal(200))
+			Expect(body).To(HaveKeyWithValue("status", "ok"))
+		})
+	})
+
+	Describe("/readyz", func() {
+		It("should be ready while GitHub is unreachable and users were never synced", func() {
+			code, body := request("/readyz")
+			Expect(code).To(Equal(200))
+			Expect(body).To(Equal(map[string]interface{}{"cache": true}))
+		})
+
+		It("should not be ready while cache is unreachable", func() {
+			cache = &fakeCache{err: keyStorages.ErrStorageConnectionFailed}
+			code, body := request("/readyz")
+			Expect(code).To(Equal(503))
+			Expect(body).To(Equal(map[string]interface{}{"cache": false}))
+		})
+	})
+
+	Describe("/status", func() {
+		It("should report unreachable GitHub and missing sync", func() {
+			code, body := request("/status")
+			Expect(code).To(Equal(200))
+			Expect(body["github"]).To(HaveKeyWithValue("reachable", false))
+			Expect(body["github"]).To(HaveKeyWithValue("error", "connection refused"))
+			Expect(body["cache"]).To(Equal(map[string]interface{}{"backend": "redis", "reachable": true}))
+			Expect(body["sync"]).To(HaveKeyWithValue("recent", false))
+		})
+
+		It("should report GitHub rate limit", func() {
+			githubClient = &fakeRateLimit{rate: &github.Rate{Limit: 5000, Remaining: 4999, Reset: github.Timestamp{Time: time.Now()}}}
+			_, body := request("/status")
+			Expect(body["github"]).To(HaveKeyWithValue("reachable", true))
+			Expect(body["github"]).To(HaveKeyWithValue("rate_limit_remaining", BeNumerically("==", 4999)))
+		})
+	})
+})
@@ -0,0 +1,163 @@
+/*
+ * Github Authorized Keys - Use GitHub teams to manage system user accounts and authorized_keys
+ *
+ * Copyright 2016 Cloud Posse, LLC <hello@cloudposse.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"github.com/terjekv/github-authorized-keys/accounts"
+	"github.com/terjekv/github-authorized-keys/audit"
+	"github.com/terjekv/github-authorized-keys/config"
+	keyStorages "github.com/terjekv/github-authorized-keys/key_storages"
+	"github.com/terjekv/github-authorized-keys/metrics"
+	"golang.org/x/time/rate"
+)
+
+// GitHub login is up to 39 alphanumeric characters or single hyphens, and cannot begin or end with a hyphen
+const githubLoginMaxLength = 39
+
+var githubLoginPattern = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// Limiters of clients that did not make requests for this long are dropped
+const clientLimiterIdleTimeout = 10 * time.Minute
+
+func validLogin(name string) bool {
+	return len(name) <= githubLoginMaxLength && githubLoginPattern.MatchString(strings.ToLower(name))
+}
+
+// githubLogin - return GitHub login of linux user {name} according to user name mapping
+func githubLogin(cfg config.Config, name string) (string, bool) {
+	login, ok := accounts.GithubLogin(cfg.NameMapping(), cfg.StatePath(), name)
+	return login, ok && validLogin(login)
+}
+
+// validateUser - reject requests for names that could not be mapped to GitHub logins before any backend call
+func validateUser(holder *config.Holder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Params.ByName("name")
+		if name == "" {
+			c.Next()
+			return
+		}
+		if _, ok := githubLogin(holder.Load(), name); ok {
+			c.Next()
+			return
+		}
+
+		metrics.AuthorizedKeysRequests.WithLabelValues(metrics.OutcomeRejected).Inc()
+		audit.Log(audit.Record{User: name, Decision: audit.DecisionDeny, Reason: audit.ReasonInvalidUser, Client: c.ClientIP()})
+		c.AbortWithStatusJSON(400, gin.H{"error": "invalid username"})
+	}
+}
+
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// clientLimiters - token bucket per client address
+type clientLimiters struct {
+	mutex     sync.Mutex
+	clients   map[string]*clientLimiter
+	limit     rate.Limit
+	burst     int
+	lastSweep time.Time
+}
+
+func newClientLimiters(perMinute, burst int) *clientLimiters {
+	return &clientLimiters{
+		clients: map[string]*clientLimiter{},
+		limit:   rate.Limit(float64(perMinute) / 60),
+		burst:   burst,
+	}
+}
+
+func (l *clientLimiters) allow(client string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastSweep) > clientLimiterIdleTimeout {
+		for address, entry := range l.clients {
+			if now.Sub(entry.lastSeen) > clientLimiterIdleTimeout {
+				delete(l.clients, address)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	entry, ok := l.clients[client]
+	if !ok {
+		entry = &clientLimiter{limiter: rate.NewLimiter(l.limit, l.burst)}
+		l.clients[client] = entry
+	}
+	entry.lastSeen = now
+	return entry.limiter.Allow()
+}
+
+// exemptClient - local clients are not limited: sshd AuthorizedKeysCommand lookups of all users come from loopback
+// or unix socket (no client address), so a shared bucket would lock out logins host-wide
+func exemptClient(client string) bool {
+	if client == "" {
+		return true
+	}
+	ip := net.ParseIP(client)
+	return ip != nil && ip.IsLoopback()
+}
+
+// limitClients - respond 429 when remote client exceeds its request rate, disabled when {perMinute} is 0
+func limitClients(perMinute, burst int) gin.HandlerFunc {
+	if perMinute <= 0 {
+		return func(c *gin.Context) { c.Next() }
+	}
+
+	limiters := newClientLimiters(perMinute, burst)
+	return func(c *gin.Context) {
+		if exemptClient(c.ClientIP()) || limiters.allow(c.ClientIP()) {
+			c.Next()
+			return
+		}
+
+		log.WithFields(log.Fields{"class": "server", "method": "limitClients", "client": c.ClientIP()}).Warn("Client exceeded request rate")
+		metrics.AuthorizedKeysRequests.WithLabelValues(metrics.OutcomeRejected).Inc()
+		audit.Log(audit.Record{User: strings.ToLower(c.Params.ByName("name")), Decision: audit.DecisionDeny, Reason: audit.ReasonRateLimited, Client: c.ClientIP()})
+		c.AbortWithStatusJSON(429, gin.H{"error": "too many requests"})
+	}
+}
+
+// newBackendLimiter - limiter of GitHub lookups across all clients, nil when {perMinute} is 0
+func newBackendLimiter(perMinute int) *rate.Limiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(float64(perMinute)/60), perMinute)
+}
+
+// unavailableSource - source used when backend lookups are over the limit, so only cached keys are served
+type unavailableSource struct{}
+
+func (unavailableSource) GetEntry(string) (*keyStorages.Entry, error) {
+	return nil, keyStorages.ErrStorageConnectionFailed
+}
@@ -0,0 +1,125 @@
+/*
+ * Github Authorized Keys - Use GitHub teams to manage system user accounts and authorized_keys
+ *
+ * Copyright 2016 Cloud Posse, LLC <hello@cloudposse.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/terjekv/github-authorized-keys/config"
+)
+
+var _ = Describe("Limits", func() {
+	request := func(router *gin.Engine, path, remoteAddr string) int {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = remoteAddr
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	Describe("clientLimiters", func() {
+		It("should allow burst and keep separate bucket per client", func() {
+			limiters := newClientLimiters(1, 2)
+			Expect(limiters.allow("10.0.0.1")).To(BeTrue())
+			Expect(limiters.allow("10.0.0.1")).To(BeTrue())
+			Expect(limiters.allow("10.0.0.1")).To(BeFalse())
+			Expect(limiters.allow("10.0.0.2")).To(BeTrue())
+		})
+	})
+
+	DescribeTable("exemptClient()",
+		func(client string, exempt bool) {
+			Expect(exemptClient(client)).To(Equal(exempt))
+		},
+		Entry("unix socket", "", true),
+		Entry("IPv4 loopback", "127.0.0.1", true),
+		Entry("IPv6 loopback", "::1", true),
+		Entry("remote client", "10.0.0.7", false),
+	)
+
+	Describe("limitClients()", func() {
+		var router *gin.Engine
+
+		BeforeEach(func() {
+			router = gin.New()
+			router.SetTrustedProxies(nil)
+			router.GET("/user/:name/authorized_keys", limitClients(1, 1), func(c *gin.Context) { c.String(200, "") })
+		})
+
+		It("should reject remote client over the limit", func() {
+			Expect(request(router, "/user/goruha/authorized_keys", "10.0.0.7:40000")).To(Equal(200))
+			Expect(request(router, "/user/goruha/authorized_keys", "10.0.0.7:40000")).To(Equal(429))
+		})
+
+		It("should not limit loopback clients", func() {
+			for i := 0; i < 5; i++ {
+				Expect(request(router, "/user/goruha/authorized_keys", "127.0.0.1:40000")).To(Equal(200))
+			}
+		})
+
+		It("should not limit unix socket clients", func() {
+			for i := 0; i < 5; i++ {
+				Expect(request(router, "/user/goruha/authorized_keys", "@")).To(Equal(200))
+			}
+		})
+
+		It("should be disabled by zero rate", func() {
+			router = gin.New()
+			router.GET("/", limitClients(0, 0), func(c *gin.Context) { c.String(200, "") })
+			for i := 0; i < 5; i++ {
+				Expect(request(router, "/", "10.0.0.7:40000")).To(Equal(200))
+			}
+		})
+	})
+
+	Describe("listUsers()", func() {
+		It("should answer 429 without GitHub lookup when backend limit is exhausted", func() {
+			backend := newBackendLimiter(1)
+			Expect(backend.Allow()).To(BeTrue())
+
+			router := gin.New()
+			router.GET("/v1/users", listUsers(config.NewHolder(config.Config{}), backend))
+			Expect(request(router, "/v1/users", "127.0.0.1:40000")).To(Equal(http.StatusTooManyRequests))
+		})
+
+		It("should reserve token for every GitHub call of the list", func() {
+			cfg := config.Config{GithubAdminTeamName: "ssh", GithubUserTeamName: "users"}
+			Expect(rosterCalls(cfg)).To(Equal(5))
+
+			backend := newBackendLimiter(4)
+			router := gin.New()
+			router.GET("/v1/users", listUsers(config.NewHolder(cfg), backend))
+			Expect(request(router, "/v1/users", "127.0.0.1:40000")).To(Equal(http.StatusTooManyRequests))
+			// Rejected request did not take any token
+			Expect(backend.AllowN(time.Now(), 4)).To(BeTrue())
+		})
+	})
+
+	Describe("newBackendLimiter()", func() {
+		It("should be disabled by zero rate", func() {
+			Expect(newBackendLimiter(0)).To(BeNil())
+		})
+	})
+})
@@ -0,0 +1,97 @@
+/*
+ * Github Authorized Keys - Use GitHub teams to manage system user accounts and authorized_keys
+ *
+ * Copyright 2016 Cloud Posse, LLC <hello@cloudposse.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"bufio"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/terjekv/github-authorized-keys/config"
+	keyStorages "github.com/terjekv/github-authorized-keys/key_storages"
+	"golang.org/x/time/rate"
+)
+
+// mapCache - fallback cache kept in memory
+type mapCache map[string]string
+
+func (c mapCache) Get(key string) (string, error) {
+	value, ok := c[key]
+	if !ok {
+		return "", keyStorages.ErrStorageKeyNotFound
+	}
+	return value, nil
+}
+
+func (c mapCache) Set(key, value string) error {
+	c[key] = value
+	return nil
+}
+
+func (c mapCache) Remove(key string) error {
+	delete(c, key)
+	return nil
+}
+
+// scrape - return value of {metric} exposed on /metrics of {router}, 0 when it is not exposed
+func scrape(router *gin.Engine, metric string) float64 {
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	Expect(recorder.Code).To(Equal(200))
+
+	scanner := bufio.NewScanner(recorder.Body)
+	for scanner.Scan() {
+		if value := strings.TrimPrefix(scanner.Text(), metric+" "); value != scanner.Text() {
+			number, err := strconv.ParseFloat(value, 64)
+			Expect(err).To(BeNil())
+			return number
+		}
+	}
+	return 0
+}
+
+var _ = Describe("Metrics", func() {
+	It("should count lookup outcome of authorized_keys request", func() {
+		cache := mapCache{}
+		entry := &keyStorages.Entry{Login: "alice", Role: keyStorages.RoleUser, Keys: []keyStorages.Key{{Key: "ssh-ed25519 AAAA"}}, FetchedAt: time.Now()}
+		cache["alice"], _ = entry.Marshal()
+
+		// Exhausted backend limiter serves keys from cache without GitHub lookups
+		router := gin.New()
+		router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+		router.GET("/user/:name/authorized_keys", authorizedKeys(config.NewHolder(config.Config{}), cache, rate.NewLimiter(0, 0)))
+
+		metric := `github_authorized_keys_authorized_keys_requests_total{outcome="fallback"}`
+		before := scrape(router, metric)
+
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest("GET", "/user/alice/authorized_keys", nil))
+		Expect(recorder.Code).To(Equal(200))
+		Expect(recorder.Body.String()).To(ContainSubstring("ssh-ed25519 AAAA"))
+
+		Expect(scrape(router, metric)).To(Equal(before + 1))
+		Expect(scrape(router, "github_authorized_keys_authorized_keys_request_duration_seconds_count")).To(BeNumerically(">", 0))
+	})
+})
@@ -19,51 +19,374 @@
 package server
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/sirupsen/logrus"
+	"github.com/terjekv/github-authorized-keys/audit"
 	"github.com/terjekv/github-authorized-keys/config"
 	keyStorages "github.com/terjekv/github-authorized-keys/key_storages"
+	"github.com/terjekv/github-authorized-keys/metrics"
+	"golang.org/x/time/rate"
 )
 
-// Run - start http server
-func Run(cfg config.Config) {
+// Run - start http server and serve until {ctx} is done, then drain in-flight requests.
+// Requests use config current at the time they are made, listeners, cache and rate limits are set up from config on start.
+func Run(ctx context.Context, holder *config.Holder) {
+	logger := log.WithFields(log.Fields{"class": "server", "method": "Run"})
+	cfg := holder.Load()
+
+	fallbackStorage, err := newFallbackCache(cfg)
+	if err != nil {
+		logger.Errorf("Unable to create fallback cache, caching disabled: %v", err)
+		fallbackStorage = &keyStorages.NilStorage{}
+	}
+
+	// Client used only by status, so rate limit checks do not look up organization on every call
+	probes := &statusClient{}
 
 	router := gin.Default()
 	router.SetTrustedProxies(nil)
 
-	router.GET("/user/:name/authorized_keys", func(c *gin.Context) {
+	router.GET("/healthz", healthz)
+	router.GET("/readyz", readyz(holder, fallbackStorage))
+	router.GET("/status", statusz(holder, probes, fallbackStorage))
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
+	// Routes that could trigger GitHub lookups are rate limited and validate username
+	backendLimiter := newBackendLimiter(cfg.RateLimitBackend)
+	limited := router.Group("/", requireClientCert(holder), limitClients(cfg.RateLimitClient, cfg.RateLimitClientBurst), validateUser(holder))
+
+	limited.GET("/user/:name/authorized_keys", authorizedKeys(holder, fallbackStorage, backendLimiter))
+
+	// Roster tells who has access to the host, so it is not served to anonymous network clients
+	limited.GET("/v1/users", restrictRoster(holder), listUsers(holder, backendLimiter))
+	limited.GET("/v1/users/:name", restrictRoster(holder), describeUser(holder, fallbackStorage, backendLimiter))
+
+	// Single key matched by fingerprint and type as passed by sshd in %f and %t
+	limited.GET("/user/:name/key", func(c *gin.Context) {
+		timer := prometheus.NewTimer(metrics.AuthorizedKeysDuration)
+		defer timer.ObserveDuration()
+
+		name := strings.ToLower(c.Params.ByName("name"))
+		fingerprint, keyType := c.Query("fingerprint"), c.Query("type")
+		if fingerprint == "" {
+			c.String(400, "fingerprint is required")
+			return
+		}
+
+		entry, err := authorize(holder.Load(), fallbackStorage, backendLimiter, name, false)
+		result := outcome(entry, err)
+		if err != nil {
+			metrics.AuthorizedKeysRequests.WithLabelValues(result).Inc()
+			auditLookup(c, name, entry, err)
+			c.String(404, "")
+			return
+		}
+
+		key, found := entry.FindKey(fingerprint, keyType)
+		// Audit only the key that is returned
+		matched := *entry
+		matched.Keys = []keyStorages.Key{}
+		if found {
+			matched.Keys = append(matched.Keys, key)
+		}
+		auditLookup(c, name, &matched, nil)
+
+		if !found {
+			metrics.AuthorizedKeysRequests.WithLabelValues(metrics.OutcomeMiss).Inc()
+			logger.WithFields(log.Fields{"user": name, "fingerprint": fingerprint, "type": keyType}).
+				Info("No key matches fingerprint")
+			c.String(404, "")
+			return
+		}
+
+		metrics.AuthorizedKeysRequests.WithLabelValues(result).Inc()
+		recordLookup(name)
+		logger.WithFields(log.Fields{"user": name, "fingerprint": key.Fingerprint, "type": key.Type(), "key_id": key.ID, "cached": entry.Cached}).
+			Info("Key matched fingerprint")
+		c.String(200, "%v", key.Key)
+	})
+
+	listeners := []net.Listener{}
+	defer func() {
+		for _, listener := range listeners {
+			listener.Close()
+		}
+	}()
+
+	if cfg.ListenSocket != "" {
+		listener, err := listenUnix(cfg)
+		if err != nil {
+			logger.Errorf("Unable to listen on socket %v: %v", cfg.ListenSocket, err)
+			return
+		}
+		logger.Infof("Listening on unix socket %v", cfg.ListenSocket)
+		listeners = append(listeners, listener)
+	}
+
+	if cfg.Listen != "" {
+		listener, err := listenTCP(ctx, cfg)
+		if err != nil {
+			logger.Errorf("Unable to listen on %v: %v", cfg.Listen, err)
+			return
+		}
+		logger.Infof("Listening on %v (TLS: %v)", cfg.Listen, cfg.TLSCertFile != "")
+		listeners = append(listeners, listener)
+	}
+
+	serve(ctx, holder, router, listeners)
+}
+
+// serve - serve {handler} on {listeners} until {ctx} is done or serving fails,
+// then stop accepting requests and drain in-flight ones for at most shutdown timeout of current config
+func serve(ctx context.Context, holder *config.Holder, handler http.Handler, listeners []net.Listener) {
+	logger := log.WithFields(log.Fields{"class": "server", "method": "serve"})
+
+	httpServer := &http.Server{Handler: handler, ConnContext: markUnixConn}
+	errs := make(chan error, len(listeners))
+	for _, listener := range listeners {
+		listener := listener
+		go func() { errs <- httpServer.Serve(listener) }()
+	}
+
+	select {
+	case err := <-errs:
+		logger.Error(err)
+	case <-ctx.Done():
+		logger.Info("Shutting down, waiting for in-flight requests")
+	}
+
+	// Stop accepting requests and drain in-flight lookups
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), holder.Load().ShutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		logger.Errorf("Unable to drain in-flight requests: %v", err)
+	}
+}
+
+// authorizedKeys - serve all keys of user as authorized_keys file
+func authorizedKeys(holder *config.Holder, fallbackStorage keyStorages.FallbackCache, backend *rate.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		timer := prometheus.NewTimer(metrics.AuthorizedKeysDuration)
+		defer timer.ObserveDuration()
+
 		name := c.Params.ByName("name")
 		name = strings.ToLower(name)
-		key, err := authorize(cfg, name)
+		entry, err := authorize(holder.Load(), fallbackStorage, backend, name, false)
+		metrics.AuthorizedKeysRequests.WithLabelValues(outcome(entry, err)).Inc()
+		auditLookup(c, name, entry, err)
 		if err == nil {
-			c.String(200, "%v", key)
+			recordLookup(name)
+			c.String(200, "%v", entry.AuthorizedKeys())
 		} else {
 			c.String(404, "")
 		}
-	})
-
-	router.Run(cfg.Listen)
+	}
 }
 
-func authorize(cfg config.Config, userName string) (string, error) {
-	var keys *keyStorages.Proxy
+// authorize - fetch keys of user from GitHub, falling back to cache.
+// When {backend} limiter is exhausted only cached keys are served. GitHub profile is fetched only when {profile} is set.
+func authorize(cfg config.Config, fallbackStorage keyStorages.FallbackCache, backend *rate.Limiter, userName string, profile bool) (*keyStorages.Entry, error) {
+	// Requested linux user is looked up by GitHub login it is mapped from
+	login, ok := githubLogin(cfg, userName)
+	if !ok {
+		return nil, keyStorages.ErrStorageKeyNotFound
+	}
+	userName = login
+
+	if backend != nil && !backend.Allow() {
+		log.WithFields(log.Fields{"class": "server", "method": "authorize", "user": userName}).
+			Warn("Backend lookups limit exceeded, serving cached keys only")
+		keys := keyStorages.NewProxy(unavailableSource{}, fallbackStorage)
+		keys.SetStalenessPolicy(stalenessPolicy(cfg))
+		return keys.GetEntry(userName)
+	}
 
 	sourceStorage := keyStorages.NewGithubKeys(
-		cfg.GithubAPIToken,
+		cfg.GithubToken(),
 		cfg.GithubOrganization,
 		cfg.GithubAdminTeamName,
 		cfg.GithubAdminTeamID,
 		cfg.GithubUserTeamName,
 		cfg.GithubUserTeamID,
+		cfg.GithubAPIPageSize,
 	)
+	sourceStorage.Profile = profile
 
-	if len(cfg.EtcdEndpoints) > 0 {
-		fallbackStorage, _ := keyStorages.NewEtcdCache(cfg.EtcdEndpoints, cfg.EtcdPrefix, cfg.EtcdTTL)
-		keys = keyStorages.NewProxy(sourceStorage, fallbackStorage)
-	} else {
-		fallbackStorage := &keyStorages.NilStorage{}
-		keys = keyStorages.NewProxy(sourceStorage, fallbackStorage)
+	keys := keyStorages.NewProxy(sourceStorage, fallbackStorage)
+	keys.SetStalenessPolicy(stalenessPolicy(cfg))
+	return keys.GetEntry(userName)
+}
+
+func stalenessPolicy(cfg config.Config) keyStorages.StalenessPolicy {
+	return keyStorages.StalenessPolicy{
+		MaxStaleness: cfg.CacheMaxStaleness,
+		Roles: map[string]time.Duration{
+			keyStorages.RoleAdmin: cfg.CacheMaxStalenessAdmin,
+			keyStorages.RoleUser:  cfg.CacheMaxStalenessUser,
+		},
 	}
-	return keys.Get(userName)
+}
+
+// outcome - classify result of authorized_keys lookup for metrics
+func outcome(entry *keyStorages.Entry, err error) string {
+	switch {
+	case err == nil && entry.Cached:
+		return metrics.OutcomeFallback
+	case err == nil:
+		return metrics.OutcomeHit
+	case errors.Is(err, keyStorages.ErrStorageNotMember), err == keyStorages.ErrStorageKeyStale:
+		return metrics.OutcomeDenied
+	case errors.Is(err, keyStorages.ErrStorageKeyNotFound):
+		return metrics.OutcomeMiss
+	default:
+		return metrics.OutcomeError
+	}
+}
+
+// auditLookup - write audit record of key lookup, {entry} holds returned keys
+func auditLookup(c *gin.Context, name string, entry *keyStorages.Entry, err error) {
+	record := audit.Record{User: name, Decision: audit.DecisionDeny, Client: c.ClientIP()}
+
+	switch {
+	case err == nil:
+		record.Fingerprints = entry.Fingerprints()
+		record.Source = keyStorages.SourceGithub
+		record.Reason = audit.ReasonMember
+		if entry.Cached {
+			record.Source = "cache"
+			record.Reason = audit.ReasonCache
+		}
+		if len(entry.Keys) > 0 {
+			record.Decision = audit.DecisionAllow
+		} else {
+			record.Reason = audit.ReasonNoKeys
+		}
+	case errors.Is(err, keyStorages.ErrStorageNotMember):
+		record.Reason = audit.ReasonNotInTeam
+	case errors.Is(err, keyStorages.ErrStorageKeyNotFound):
+		record.Reason = audit.ReasonNotFound
+	case err == keyStorages.ErrStorageKeyStale:
+		record.Reason = audit.ReasonStaleCache
+		record.Source = "cache"
+	default:
+		record.Reason = audit.ReasonGithubError
+	}
+
+	if err := audit.Log(record); err != nil {
+		log.WithFields(log.Fields{"class": "server", "method": "auditLookup"}).Errorf("Unable to write audit record: %v", err)
+	}
+}
+
+// listenTCP - create TCP listener, serving TLS when certificate is configured.
+// Client certificates are required when client CA file is configured.
+func listenTCP(ctx context.Context, cfg config.Config) (net.Listener, error) {
+	var reloader *tlsReloader
+	if cfg.TLSCertFile != "" {
+		var err error
+		if reloader, err = newTLSReloader(cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSClientCAFile); err != nil {
+			return nil, err
+		}
+	}
+
+	listener, err := net.Listen("tcp", cfg.Listen)
+	if err != nil {
+		return nil, err
+	}
+
+	if reloader == nil {
+		return listener, nil
+	}
+	go reloader.watch(ctx, tlsReloadInterval)
+	return tls.NewListener(listener, reloader.TLSConfig()), nil
+}
+
+// newFallbackCache - create cache storage selected by config, NilStorage when caching is disabled.
+// Values are encrypted when encryption key file is configured and signed when signing or verification key file is configured.
+func newFallbackCache(cfg config.Config) (keyStorages.FallbackCache, error) {
+	cache, err := newCacheBackend(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := cache.(*keyStorages.NilStorage); ok {
+		return cache, nil
+	}
+
+	if cfg.CacheEncryptionKeyFile != "" {
+		key, err := keyStorages.LoadEncryptionKey(cfg.CacheEncryptionKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if cache, err = keyStorages.NewSealedCache(cache, key); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.CacheSigningKeyFile != "" || cfg.CacheVerifyKeyFile != "" {
+		keys, err := keyStorages.LoadSigningKeys(cfg.CacheSigningKeyFile, cfg.CacheVerifyKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cache = keyStorages.NewSignedCache(cache, keys)
+	}
+
+	return cache, nil
+}
+
+func newCacheBackend(cfg config.Config) (keyStorages.FallbackCache, error) {
+	switch {
+	case len(cfg.RedisEndpoints) > 0:
+		tlsConfig, err := redisTLSConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return keyStorages.NewRedisCache(keyStorages.RedisCacheOptions{
+			Endpoints:  cfg.RedisEndpoints,
+			MasterName: cfg.RedisMasterName,
+			Password:   cfg.RedisPassword,
+			DB:         cfg.RedisDB,
+			TLSConfig:  tlsConfig,
+			Prefix:     cfg.RedisPrefix,
+			TTL:        cfg.RedisTTL,
+		})
+
+	case len(cfg.EtcdEndpoints) > 0:
+		return keyStorages.NewEtcdCache(cfg.EtcdEndpoints, cfg.EtcdPrefix, cfg.EtcdTTL)
+
+	default:
+		return &keyStorages.NilStorage{}, nil
+	}
+}
+
+func redisTLSConfig(cfg config.Config) (*tls.Config, error) {
+	if !cfg.RedisTLS {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if cfg.RedisTLSCAFile != "" {
+		pem, err := ioutil.ReadFile(cfg.RedisTLSCAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no certificates found in " + cfg.RedisTLSCAFile)
+		}
+	}
+
+	return tlsConfig, nil
 }
@@ -0,0 +1,86 @@
+/*
+ * Github Authorized Keys - Use GitHub teams to manage system user accounts and authorized_keys
+ *
+ * Copyright 2016 Cloud Posse, LLC <hello@cloudposse.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/terjekv/github-authorized-keys/config"
+)
+
+var _ = Describe("serve()", func() {
+	It("should finish in-flight request on shutdown", func() {
+		started, release := make(chan struct{}), make(chan struct{})
+		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+			w.Write([]byte("keys"))
+		})
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).To(BeNil())
+		address := listener.Addr().String()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		stopped := make(chan struct{})
+		go func() {
+			serve(ctx, config.NewHolder(config.Config{ShutdownTimeout: 5 * time.Second}), handler, []net.Listener{listener})
+			close(stopped)
+		}()
+
+		type result struct {
+			body string
+			err  error
+		}
+		results := make(chan result, 1)
+		go func() {
+			response, err := http.Get("http://" + address + "/user/goruha/authorized_keys")
+			if err != nil {
+				results <- result{err: err}
+				return
+			}
+			defer response.Body.Close()
+			body, err := ioutil.ReadAll(response.Body)
+			results <- result{body: string(body), err: err}
+		}()
+
+		Eventually(started).Should(BeClosed())
+		cancel()
+
+		// Server waits for the request and stops accepting new ones
+		Consistently(stopped, 100*time.Millisecond).ShouldNot(BeClosed())
+		Eventually(func() error {
+			_, err := net.Dial("tcp", address)
+			return err
+		}).ShouldNot(BeNil())
+
+		close(release)
+		var got result
+		Eventually(results).Should(Receive(&got))
+		Expect(got.err).To(BeNil())
+		Expect(got.body).To(Equal("keys"))
+		Eventually(stopped).Should(BeClosed())
+	})
+})
@@ -0,0 +1,114 @@
+/*
+ * Github Authorized Keys - Use GitHub teams to manage system user accounts and authorized_keys
+ *
+ * Copyright 2016 Cloud Posse, LLC <hello@cloudposse.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/user"
+	"strconv"
+	"syscall"
+
+	"github.com/terjekv/github-authorized-keys/config"
+)
+
+type unixConnKey struct{}
+
+// markUnixConn - record in connection context that {conn} was accepted on unix socket
+func markUnixConn(ctx context.Context, conn net.Conn) context.Context {
+	_, unix := conn.LocalAddr().(*net.UnixAddr)
+	return context.WithValue(ctx, unixConnKey{}, unix)
+}
+
+// fromUnixSocket - whether {request} came through unix socket listener
+func fromUnixSocket(request *http.Request) bool {
+	unix, _ := request.Context().Value(unixConnKey{}).(bool)
+	return unix
+}
+
+// listenUnix - create unix domain socket listener with configured owner, group and mode.
+// Stale socket left by previous run is removed.
+func listenUnix(cfg config.Config) (net.Listener, error) {
+	if info, err := os.Lstat(cfg.ListenSocket); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%v exists and is not a socket", cfg.ListenSocket)
+		}
+		if err := os.Remove(cfg.ListenSocket); err != nil {
+			return nil, err
+		}
+	}
+
+	// Socket is created accessible by the process user only and opened up once owner and group are set
+	umask := syscall.Umask(0177)
+	listener, err := net.Listen("unix", cfg.ListenSocket)
+	syscall.Umask(umask)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := setupSocket(cfg); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	return listener, nil
+}
+
+// setupSocket - set owner and group of socket, then its mode
+func setupSocket(cfg config.Config) error {
+	mode, err := strconv.ParseUint(cfg.ListenSocketMode, 8, 32)
+	if err != nil {
+		return fmt.Errorf("invalid socket mode %v: %v", cfg.ListenSocketMode, err)
+	}
+
+	uid, gid := -1, -1
+
+	if cfg.ListenSocketOwner != "" {
+		owner, err := user.Lookup(cfg.ListenSocketOwner)
+		if err != nil {
+			owner, err = user.LookupId(cfg.ListenSocketOwner)
+		}
+		if err != nil {
+			return err
+		}
+		uid, _ = strconv.Atoi(owner.Uid)
+	}
+
+	if cfg.ListenSocketGroup != "" {
+		group, err := user.LookupGroup(cfg.ListenSocketGroup)
+		if err != nil {
+			group, err = user.LookupGroupId(cfg.ListenSocketGroup)
+		}
+		if err != nil {
+			return err
+		}
+		gid, _ = strconv.Atoi(group.Gid)
+	}
+
+	if uid != -1 || gid != -1 {
+		if err := os.Chown(cfg.ListenSocket, uid, gid); err != nil {
+			return err
+		}
+	}
+
+	return os.Chmod(cfg.ListenSocket, os.FileMode(mode))
+}
@@ -0,0 +1,113 @@
+/*
+ * Github Authorized Keys - Use GitHub teams to manage system user accounts and authorized_keys
+ *
+ * Copyright 2016 Cloud Posse, LLC <hello@cloudposse.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/terjekv/github-authorized-keys/config"
+)
+
+var _ = Describe("Socket", func() {
+	var (
+		dir string
+		cfg config.Config
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "gak-socket")
+		Expect(err).To(BeNil())
+		cfg = config.Config{ListenSocket: filepath.Join(dir, "keys.sock"), ListenSocketMode: "0660"}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	Describe("listenUnix()", func() {
+		It("should create socket with configured mode", func() {
+			listener, err := listenUnix(cfg)
+			Expect(err).To(BeNil())
+			defer listener.Close()
+
+			info, err := os.Stat(cfg.ListenSocket)
+			Expect(err).To(BeNil())
+			Expect(info.Mode() & os.ModeSocket).NotTo(BeZero())
+			Expect(info.Mode().Perm()).To(Equal(os.FileMode(0660)))
+
+			conn, err := net.Dial("unix", cfg.ListenSocket)
+			Expect(err).To(BeNil())
+			conn.Close()
+		})
+
+		It("should set group of socket", func() {
+			cfg.ListenSocketGroup = strconv.Itoa(os.Getgid())
+			listener, err := listenUnix(cfg)
+			Expect(err).To(BeNil())
+			defer listener.Close()
+
+			info, err := os.Stat(cfg.ListenSocket)
+			Expect(err).To(BeNil())
+			Expect(info.Sys().(*syscall.Stat_t).Gid).To(Equal(uint32(os.Getgid())))
+		})
+
+		It("should keep process umask", func() {
+			umask := syscall.Umask(0022)
+			defer syscall.Umask(umask)
+
+			listener, err := listenUnix(cfg)
+			Expect(err).To(BeNil())
+			listener.Close()
+
+			Expect(syscall.Umask(umask)).To(Equal(0022))
+		})
+
+		It("should replace stale socket", func() {
+			stale, err := net.Listen("unix", cfg.ListenSocket)
+			Expect(err).To(BeNil())
+			// Closing unix listener removes its file unless unlinking is disabled
+			stale.(*net.UnixListener).SetUnlinkOnClose(false)
+			stale.Close()
+
+			listener, err := listenUnix(cfg)
+			Expect(err).To(BeNil())
+			listener.Close()
+		})
+
+		It("should refuse to replace file that is not a socket", func() {
+			Expect(ioutil.WriteFile(cfg.ListenSocket, []byte("data"), 0644)).To(BeNil())
+			_, err := listenUnix(cfg)
+			Expect(err).To(MatchError(ContainSubstring("is not a socket")))
+		})
+
+		It("should fail on invalid mode", func() {
+			cfg.ListenSocketMode = "rw"
+			_, err := listenUnix(cfg)
+			Expect(err).To(MatchError(ContainSubstring("invalid socket mode")))
+		})
+	})
+})
@@ -0,0 +1,161 @@
+/*
+ * Github Authorized Keys - Use GitHub teams to manage system user accounts and authorized_keys
+ *
+ * Copyright 2016 Cloud Posse, LLC <hello@cloudposse.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"github.com/terjekv/github-authorized-keys/config"
+)
+
+// tlsReloadInterval - how often certificate, key and client CA files are checked for changes
+const tlsReloadInterval = time.Minute
+
+// tlsReloader - serves TLS config built from certificate, key and client CA files,
+// files are reloaded by watch when their modification time or size changed
+type tlsReloader struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+
+	mutex   sync.Mutex
+	config  *tls.Config
+	version string
+}
+
+func newTLSReloader(certFile, keyFile, clientCAFile string) (*tlsReloader, error) {
+	reloader := &tlsReloader{certFile: certFile, keyFile: keyFile, clientCAFile: clientCAFile}
+	if err := reloader.reload(); err != nil {
+		return nil, err
+	}
+	return reloader, nil
+}
+
+// TLSConfig - return config that serves certificates loaded last on every handshake
+func (r *tlsReloader) TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			r.mutex.Lock()
+			defer r.mutex.Unlock()
+			return r.config, nil
+		},
+	}
+}
+
+// watch - reload changed files every {interval} until {ctx} is done
+func (r *tlsReloader) watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.reload(); err != nil {
+				log.WithFields(log.Fields{"class": "tlsReloader", "method": "watch"}).
+					Errorf("Unable to reload certificates, keep serving previous ones: %v", err)
+			}
+		}
+	}
+}
+
+func (r *tlsReloader) reload() error {
+	version, err := r.filesVersion()
+	if err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if version == r.version {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+
+	config := &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{cert},
+	}
+
+	if r.clientCAFile != "" {
+		pem, err := ioutil.ReadFile(r.clientCAFile)
+		if err != nil {
+			return err
+		}
+		config.ClientCAs = x509.NewCertPool()
+		if !config.ClientCAs.AppendCertsFromPEM(pem) {
+			return errors.New("no certificates found in " + r.clientCAFile)
+		}
+		// Certificate is enforced by requireClientCert on key routes, so probes and scrapers could connect without it
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	if r.config != nil {
+		log.WithFields(log.Fields{"class": "tlsReloader", "method": "reload"}).Info("TLS certificates reloaded")
+	}
+
+	r.config = config
+	r.version = version
+	return nil
+}
+
+// filesVersion - return string that changes whenever any of files is modified
+func (r *tlsReloader) filesVersion() (string, error) {
+	version := ""
+	for _, path := range []string{r.certFile, r.keyFile, r.clientCAFile} {
+		if path == "" {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", err
+		}
+		version += info.ModTime().Format(time.RFC3339Nano) + "/" + strconv.FormatInt(info.Size(), 10) + ";"
+	}
+	return version, nil
+}
+
+// requireClientCert - reject TLS requests without verified client certificate when client CA is configured.
+// Requests on the unix socket are not TLS, access to them is controlled by socket permissions.
+func requireClientCert(holder *config.Holder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if holder.Load().TLSClientCAFile != "" && c.Request.TLS != nil && len(c.Request.TLS.VerifiedChains) == 0 {
+			c.AbortWithStatusJSON(403, gin.H{"error": "client certificate required"})
+			return
+		}
+		c.Next()
+	}
+}
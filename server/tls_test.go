@@ -0,0 +1,210 @@
+/*
+ * Github Authorized Keys - Use GitHub teams to manage system user accounts and authorized_keys
+ *
+ * Copyright 2016 Cloud Posse, LLC <hello@cloudposse.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/terjekv/github-authorized-keys/config"
+)
+
+// testCert - certificate with its key, signed by {parent} or self-signed when {parent} is nil
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newTestCert(serial int64, parent *testCert) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	Expect(err).To(BeNil())
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "github-authorized-keys test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA, template.BasicConstraintsValid = true, true
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	Expect(err).To(BeNil())
+	cert, err := x509.ParseCertificate(der)
+	Expect(err).To(BeNil())
+	return &testCert{cert: cert, key: key, der: der}
+}
+
+func (c *testCert) write(certFile, keyFile string) {
+	Expect(ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.der}), 0644)).To(BeNil())
+	if keyFile == "" {
+		return
+	}
+	der, err := x509.MarshalECPrivateKey(c.key)
+	Expect(err).To(BeNil())
+	Expect(ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0600)).To(BeNil())
+}
+
+func (c *testCert) tlsCertificate() tls.Certificate {
+	return tls.Certificate{Certificate: [][]byte{c.der}, PrivateKey: c.key}
+}
+
+var _ = Describe("TLS", func() {
+	var (
+		dir                             string
+		certFile, keyFile, clientCAFile string
+		ca                              *testCert
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "gak-tls")
+		Expect(err).To(BeNil())
+
+		certFile, keyFile, clientCAFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"), filepath.Join(dir, "ca.pem")
+		ca = newTestCert(1, nil)
+		ca.write(clientCAFile, "")
+		newTestCert(2, ca).write(certFile, keyFile)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	// serve - start mutual TLS server with probe and key routes, return its address and stop function
+	serve := func(reloader *tlsReloader) (string, func()) {
+		holder := config.NewHolder(config.Config{TLSCertFile: certFile, TLSKeyFile: keyFile, TLSClientCAFile: clientCAFile})
+
+		router := gin.New()
+		router.GET("/healthz", healthz)
+		router.Group("/", requireClientCert(holder)).GET("/user/:name/authorized_keys", func(c *gin.Context) {
+			c.String(200, "keys")
+		})
+
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).To(BeNil())
+		server := &http.Server{Handler: router}
+		go server.Serve(tls.NewListener(listener, reloader.TLSConfig()))
+		return listener.Addr().String(), func() { server.Close() }
+	}
+
+	client := func(certificates ...tls.Certificate) *http.Client {
+		roots := x509.NewCertPool()
+		roots.AddCert(ca.cert)
+		return &http.Client{Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{RootCAs: roots, Certificates: certificates},
+			DisableKeepAlives: true,
+		}}
+	}
+
+	get := func(client *http.Client, url string) int {
+		response, err := client.Get(url)
+		Expect(err).To(BeNil())
+		response.Body.Close()
+		return response.StatusCode
+	}
+
+	It("should serve probes without client certificate and require it on key routes", func() {
+		reloader, err := newTLSReloader(certFile, keyFile, clientCAFile)
+		Expect(err).To(BeNil())
+		address, stop := serve(reloader)
+		defer stop()
+
+		anonymous := client()
+		Expect(get(anonymous, "https://"+address+"/healthz")).To(Equal(200))
+		Expect(get(anonymous, "https://"+address+"/user/alice/authorized_keys")).To(Equal(403))
+
+		enrolled := client(newTestCert(3, ca).tlsCertificate())
+		Expect(get(enrolled, "https://"+address+"/user/alice/authorized_keys")).To(Equal(200))
+	})
+
+	It("should reject client certificate not signed by client CA", func() {
+		reloader, err := newTLSReloader(certFile, keyFile, clientCAFile)
+		Expect(err).To(BeNil())
+		address, stop := serve(reloader)
+		defer stop()
+
+		_, err = client(newTestCert(4, newTestCert(5, nil)).tlsCertificate()).Get("https://" + address + "/healthz")
+		Expect(err).NotTo(BeNil())
+	})
+
+	It("should serve renewed certificate after reload", func() {
+		reloader, err := newTLSReloader(certFile, keyFile, clientCAFile)
+		Expect(err).To(BeNil())
+		address, stop := serve(reloader)
+		defer stop()
+
+		serial := func() int64 {
+			response, err := client().Get("https://" + address + "/healthz")
+			Expect(err).To(BeNil())
+			response.Body.Close()
+			return response.TLS.PeerCertificates[0].SerialNumber.Int64()
+		}
+		Expect(serial()).To(Equal(int64(2)))
+
+		newTestCert(6, ca).write(certFile, keyFile)
+		later := time.Now().Add(time.Minute)
+		Expect(os.Chtimes(certFile, later, later)).To(BeNil())
+
+		// Certificate files are not checked on handshake
+		Expect(serial()).To(Equal(int64(2)))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go reloader.watch(ctx, 10*time.Millisecond)
+		Eventually(serial).Should(Equal(int64(6)))
+	})
+
+	It("should keep previous certificate when reload fails", func() {
+		reloader, err := newTLSReloader(certFile, keyFile, clientCAFile)
+		Expect(err).To(BeNil())
+
+		Expect(ioutil.WriteFile(certFile, []byte("broken"), 0644)).To(BeNil())
+		Expect(reloader.reload()).NotTo(BeNil())
+
+		config, err := reloader.TLSConfig().GetConfigForClient(nil)
+		Expect(err).To(BeNil())
+		Expect(config.Certificates).To(HaveLen(1))
+	})
+})
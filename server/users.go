@@ -0,0 +1,252 @@
+/*
+ * Github Authorized Keys - Use GitHub teams to manage system user accounts and authorized_keys
+ *
+ * Copyright 2016 Cloud Posse, LLC <hello@cloudposse.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/terjekv/github-authorized-keys/api"
+	"github.com/terjekv/github-authorized-keys/config"
+	keyStorages "github.com/terjekv/github-authorized-keys/key_storages"
+	"golang.org/x/time/rate"
+)
+
+// lookups - time of last successful key lookup per user since start, kept in memory only
+var lookups = struct {
+	sync.Mutex
+	last map[string]time.Time
+}{last: map[string]time.Time{}}
+
+func recordLookup(name string) {
+	lookups.Lock()
+	defer lookups.Unlock()
+	lookups.last[name] = time.Now().UTC()
+}
+
+func lastLookup(name string) *time.Time {
+	lookups.Lock()
+	defer lookups.Unlock()
+	if last, ok := lookups.last[name]; ok {
+		return &last
+	}
+	return nil
+}
+
+type keyInfo struct {
+	ID          int64      `json:"id,omitempty"`
+	Type        string     `json:"type"`
+	Bits        int        `json:"bits"`
+	Fingerprint string     `json:"fingerprint"`
+	CreatedAt   *time.Time `json:"created_at,omitempty"`
+	Key         string     `json:"key"`
+}
+
+type userInfo struct {
+	Login     string    `json:"login"`
+	ID        int64     `json:"id,omitempty"`
+	Team      string    `json:"team,omitempty"`
+	Role      string    `json:"role,omitempty"`
+	Groups    []string  `json:"groups"`
+	Keys      []keyInfo `json:"keys"`
+	Source    string    `json:"source"`
+	FetchedAt time.Time `json:"fetched_at"`
+	Cached    bool      `json:"cached"`
+}
+
+type teamMember struct {
+	Login      string     `json:"login"`
+	ID         int64      `json:"id,omitempty"`
+	Role       string     `json:"role"`
+	Teams      []string   `json:"teams"`
+	Groups     []string   `json:"groups"`
+	LinuxUser  string     `json:"linux_user"`
+	Account    string     `json:"account"`
+	LastLookup *time.Time `json:"last_lookup,omitempty"`
+}
+
+// listUsers - return union of configured teams members with their role, linux account status and last key lookup time.
+// Request is rejected when {backend} limiter of GitHub lookups has no token for every call the list takes,
+// there is no cached list to serve.
+func listUsers(holder *config.Holder, backend *rate.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := holder.Load()
+		if backend != nil && !backend.AllowN(time.Now(), rosterCalls(cfg)) {
+			c.JSON(429, gin.H{"error": "GitHub lookups limit exceeded"})
+			return
+		}
+
+		members, err := collectMembers(cfg)
+		if err != nil {
+			c.JSON(502, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(200, members)
+	}
+}
+
+// rosterCalls - number of GitHub API calls collectMembers makes: organization lookup, then team and its members per configured team.
+// Teams and members over one page take more calls, they are not known in advance.
+func rosterCalls(cfg config.Config) int {
+	calls := 1
+	if cfg.GithubAdminTeamName != "" || cfg.GithubAdminTeamID != 0 {
+		calls += 2
+	}
+	if cfg.GithubUserTeamName != "" || cfg.GithubUserTeamID != 0 {
+		calls += 2
+	}
+	return calls
+}
+
+// restrictRoster - serve team roster only on unix socket, to clients with verified certificate,
+// or to any client when enabled by config
+func restrictRoster(holder *config.Holder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		request := c.Request
+		if fromUnixSocket(request) || (request.TLS != nil && len(request.TLS.VerifiedChains) > 0) || holder.Load().UsersAPITCP {
+			c.Next()
+			return
+		}
+		c.AbortWithStatusJSON(403, gin.H{"error": "users API is available on unix socket or with client certificate only"})
+	}
+}
+
+func collectMembers(cfg config.Config) ([]teamMember, error) {
+	client := api.NewGithubClient(cfg.GithubToken(), cfg.GithubOrganization)
+	client.SetPageSize(cfg.GithubAPIPageSize)
+	linux := api.NewConfiguredLinux(cfg)
+
+	teams := []struct {
+		name string
+		id   int
+		role string
+	}{
+		{cfg.GithubAdminTeamName, cfg.GithubAdminTeamID, keyStorages.RoleAdmin},
+		{cfg.GithubUserTeamName, cfg.GithubUserTeamID, keyStorages.RoleUser},
+	}
+
+	mapping := cfg.NameMapping()
+	byLogin := map[string]*teamMember{}
+	for _, configured := range teams {
+		if configured.name == "" && configured.id == 0 {
+			continue
+		}
+
+		team, err := client.GetTeam(configured.name, configured.id)
+		if err != nil {
+			return nil, err
+		}
+
+		users, err := client.GetTeamMembers(team)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, user := range users {
+			login := strings.ToLower(user.GetLogin())
+			name := mapping.LinuxName(login)
+			member, ok := byLogin[login]
+			// Admin team is checked first, so admins keep their role and groups as in sync
+			if !ok {
+				member = &teamMember{
+					Login:      user.GetLogin(),
+					ID:         user.GetID(),
+					Role:       configured.role,
+					Teams:      []string{},
+					Groups:     roleGroups(cfg, configured.role),
+					LinuxUser:  name,
+					Account:    linux.UserStatus(name),
+					LastLookup: lastLookup(name),
+				}
+				byLogin[login] = member
+			}
+			member.Teams = append(member.Teams, team.GetName())
+		}
+	}
+
+	members := []teamMember{}
+	for _, member := range byLogin {
+		members = append(members, *member)
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].Login < members[j].Login })
+
+	return members, nil
+}
+
+// describeUser - return JSON describing user's access: GitHub identity, matched team and role,
+// linux groups applied by sync and keys with metadata
+func describeUser(holder *config.Holder, fallbackStorage keyStorages.FallbackCache, backend *rate.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := holder.Load()
+		name := strings.ToLower(c.Params.ByName("name"))
+
+		entry, err := authorize(cfg, fallbackStorage, backend, name, true)
+		switch {
+		case err == nil:
+			c.JSON(200, newUserInfo(cfg, entry))
+		case errors.Is(err, keyStorages.ErrStorageKeyNotFound), err == keyStorages.ErrStorageKeyStale:
+			c.JSON(404, gin.H{"error": err.Error()})
+		default:
+			c.JSON(502, gin.H{"error": err.Error()})
+		}
+	}
+}
+
+func newUserInfo(cfg config.Config, entry *keyStorages.Entry) userInfo {
+	info := userInfo{
+		Login:     entry.Login,
+		ID:        entry.GithubUserID,
+		Team:      entry.Team,
+		Role:      entry.Role,
+		Groups:    roleGroups(cfg, entry.Role),
+		Keys:      []keyInfo{},
+		Source:    entry.Source,
+		FetchedAt: entry.FetchedAt,
+		Cached:    entry.Cached,
+	}
+
+	for _, key := range entry.Keys {
+		info.Keys = append(info.Keys, keyInfo{
+			ID:          key.ID,
+			Type:        key.Type(),
+			Bits:        key.Bits(),
+			Fingerprint: key.Fingerprint,
+			CreatedAt:   key.CreatedAt,
+			Key:         key.Key,
+		})
+	}
+
+	return info
+}
+
+// roleGroups - linux groups sync applies to members of {role}
+func roleGroups(cfg config.Config, role string) []string {
+	switch role {
+	case keyStorages.RoleAdmin:
+		return cfg.UserAdminGroups
+	case keyStorages.RoleUser:
+		return cfg.UserUserGroups
+	default:
+		return []string{}
+	}
+}
@@ -0,0 +1,79 @@
+/*
+ * Github Authorized Keys - Use GitHub teams to manage system user accounts and authorized_keys
+ *
+ * Copyright 2016 Cloud Posse, LLC <hello@cloudposse.com>
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *   http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/gin-gonic/gin"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/terjekv/github-authorized-keys/config"
+)
+
+var _ = Describe("restrictRoster()", func() {
+	serve := func(cfg config.Config, request *http.Request) int {
+		router := gin.New()
+		router.GET("/v1/users", restrictRoster(config.NewHolder(cfg)), func(c *gin.Context) { c.String(200, "") })
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return recorder.Code
+	}
+
+	It("should reject anonymous TCP client", func() {
+		Expect(serve(config.Config{}, httptest.NewRequest("GET", "/v1/users", nil))).To(Equal(403))
+	})
+
+	It("should serve unix socket client", func() {
+		request := httptest.NewRequest("GET", "/v1/users", nil)
+		ctx := markUnixConn(context.Background(), fakeConn{local: &net.UnixAddr{Name: "/run/gak.sock", Net: "unix"}})
+		Expect(serve(config.Config{}, request.WithContext(ctx))).To(Equal(200))
+	})
+
+	It("should not treat TCP connection as unix socket", func() {
+		request := httptest.NewRequest("GET", "/v1/users", nil)
+		ctx := markUnixConn(context.Background(), fakeConn{local: &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: 301}})
+		Expect(serve(config.Config{}, request.WithContext(ctx))).To(Equal(403))
+	})
+
+	It("should serve client with verified certificate", func() {
+		request := httptest.NewRequest("GET", "/v1/users", nil)
+		request.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{&x509.Certificate{}}}}
+		Expect(serve(config.Config{}, request)).To(Equal(200))
+	})
+
+	It("should serve any client when enabled", func() {
+		Expect(serve(config.Config{UsersAPITCP: true}, httptest.NewRequest("GET", "/v1/users", nil))).To(Equal(200))
+	})
+})
+
+// fakeConn - connection with given local address
+type fakeConn struct {
+	net.Conn
+	local net.Addr
+}
+
+func (c fakeConn) LocalAddr() net.Addr {
+	return c.local
+}